@@ -0,0 +1,153 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// accessLogFormats are the accepted values of -access_log_format.
+var accessLogFormats = []string{"text", "clf", "combined", "json"}
+
+// checkAccessLogFormat reports an unknown -access_log_format.
+func checkAccessLogFormat(format string) error {
+	for _, f := range accessLogFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("-access_log_format must be one of %s, not %q", strings.Join(accessLogFormats, ", "), format)
+}
+
+// pathPatterns is a list of URL path patterns, each either a path.Match
+// pattern, or, if it ends with a slash, a prefix.
+type pathPatterns []string
+
+// parsePathPatterns parses comma-separated list of path patterns.
+func parsePathPatterns(s string) pathPatterns {
+	var patterns pathPatterns
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+func (pp pathPatterns) match(urlPath string) bool {
+	for _, p := range pp {
+		if strings.HasSuffix(p, "/") {
+			if strings.HasPrefix(urlPath, p) {
+				return true
+			}
+		} else if ok, _ := path.Match(p, urlPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// accessLogHandler logs every request (with status, size and duration)
+// in format (see accessLogFormats), except 404 responses to paths matching quiet404, of which only every
+// sample-th is logged (none if sample is 0), so bots probing for
+// icons and such don't flood the log.
+type accessLogHandler struct {
+	h        http.Handler
+	quiet404 pathPatterns
+	sample   int64
+	format   string
+
+	quieted int64 // 404s matching quiet404 so far
+}
+
+func (h *accessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	h.h.ServeHTTP(rec, r)
+	if rec.status() == http.StatusNotFound && h.quiet404.match(r.URL.Path) {
+		n := atomic.AddInt64(&h.quieted, 1)
+		if h.sample <= 0 || n%h.sample != 1%h.sample {
+			return
+		}
+	}
+	switch h.format {
+	case "clf", "combined":
+		// the lines carry their own timestamps, without the log prefix
+		fmt.Fprintln(log.Writer(), clfLine(r, rec, start, h.format == "combined"))
+	case "json":
+		data, _ := json.Marshal(accessLogEntry{
+			Time:       start.Format(time.RFC3339Nano),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			URI:        r.URL.RequestURI(),
+			Proto:      r.Proto,
+			Status:     rec.status(),
+			Bytes:      rec.written,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+		})
+		fmt.Fprintln(log.Writer(), string(data))
+	default:
+		log.Printf("%s %s %s %d %d %v", r.RemoteAddr, r.Method, r.URL.RequestURI(), rec.status(), rec.written, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// accessLogEntry is a line of -access_log_format=json.
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RemoteAddr string  `json:"remote_addr"`
+	Method     string  `json:"method"`
+	URI        string  `json:"uri"`
+	Proto      string  `json:"proto"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	Referer    string  `json:"referer,omitempty"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+}
+
+// clfLine formats the request in NCSA Common Log Format,
+// or Combined Log Format (with referer and user agent) if combined is set.
+func clfLine(r *http.Request, rec *statusRecorder, start time.Time, combined bool) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = clfEscape(u)
+	}
+	size := "-"
+	if rec.written > 0 {
+		size = strconv.FormatInt(rec.written, 10)
+	}
+	line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+		host, user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, clfEscape(r.URL.RequestURI()), r.Proto, rec.status(), size)
+	if combined {
+		line += fmt.Sprintf(` "%s" "%s"`, clfEscape(r.Referer()), clfEscape(r.UserAgent()))
+	}
+	return line
+}
+
+// clfEscape escapes quotes, backslashes and control characters of
+// a quoted CLF field like Apache does.
+func clfEscape(s string) string {
+	if s == "" {
+		return "-"
+	}
+	q := strconv.Quote(s)
+	return q[1 : len(q)-1]
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// rateWindow is the number of seconds requestRate averages over.
+const rateWindow = 60
+
+// requestRate counts events per second in a ring of rateWindow buckets,
+// without locking. An event racing with a bucket being recycled may be lost,
+// which is fine for a rate.
+type requestRate struct {
+	buckets [rateWindow]struct {
+		sec   atomic.Int64 // Unix second the bucket counts
+		count atomic.Int64
+	}
+}
+
+func (r *requestRate) add(now time.Time) {
+	sec := now.Unix()
+	b := &r.buckets[sec%rateWindow]
+	if old := b.sec.Load(); old != sec && b.sec.CompareAndSwap(old, sec) {
+		b.count.Store(0)
+	}
+	b.count.Add(1)
+}
+
+// perSecond returns the average number of events per second over the last rateWindow seconds.
+func (r *requestRate) perSecond(now time.Time) float64 {
+	sec := now.Unix()
+	var n int64
+	for i := range r.buckets {
+		b := &r.buckets[i]
+		if s := b.sec.Load(); s > sec-rateWindow && s <= sec {
+			n += b.count.Load()
+		}
+	}
+	return float64(n) / rateWindow
+}
+
+// publishActivityVars exports the activity request rate and
+// the idle time of h via expvar, for autoscalers to consume.
+func publishActivityVars(h *lastActivityHTTPHandler) {
+	expvar.Publish("activity_requests_per_second", expvar.Func(func() interface{} {
+		return h.rate.perSecond(time.Now())
+	}))
+	expvar.Publish("activity_idle_seconds", expvar.Func(func() interface{} {
+		return time.Since(h.last()).Seconds()
+	}))
+}
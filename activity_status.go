@@ -0,0 +1,64 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// statusFileInterval is how often -status_file is rewritten.
+const statusFileInterval = 10 * time.Second
+
+// writeStatusFile periodically writes the last activity time of h to filename
+// for shell scripts, as lines like
+//
+//	last_activity=1500000000
+//	idle_seconds=42
+func writeStatusFile(h *lastActivityHTTPHandler, filename string) {
+	for {
+		last := h.last()
+		data := fmt.Sprintf("last_activity=%d\nidle_seconds=%d\n", last.Unix(), int64(time.Since(last).Seconds()))
+		if err := writeFileAtomic(filename, []byte(data)); err != nil {
+			log.Print("Writing status file: ", err)
+		}
+		time.Sleep(statusFileInterval)
+	}
+}
+
+// logIdleOnSignal logs for how long h has been idle upon SIGUSR2.
+func logIdleOnSignal(h *lastActivityHTTPHandler) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR2)
+	for range c {
+		idle := time.Since(h.last())
+		log.Printf("Idle for %v, shutting down in %v", idle.Round(time.Second), h.remaining().Round(time.Second))
+	}
+}
+
+// writeFileAtomic replaces filename with data, so readers never see a partial file.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+)
+
+// startAdminServer starts serving http.DefaultServeMux, which has the
+// debug handlers (pprof, expvar) registered, on a separate listener.
+// If addr has no host part, it binds to localhost only.
+// The admin listener doesn't participate in the inactivity timer.
+func startAdminServer(addr string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		fatal("Invalid admin address: ", err)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		fatal("Failed to listen on admin address ", err)
+	}
+	if *verbose {
+		log.Printf("admin address = %s", ln.Addr())
+	}
+	go func() {
+		fatal(http.Serve(ln, nil))
+	}()
+}
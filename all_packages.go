@@ -0,0 +1,74 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+
+	"golang.org/x/tools/godoc"
+)
+
+// allPackagesFlushInterval is how many rows of /all are written between flushes.
+const allPackagesFlushInterval = 500
+
+// allPackagesHandler serves /all: a single page listing every package
+// with its synopsis, for searching in the browser. Internal packages are
+// listed with -show_internal, like in /pkg/. The rows are streamed, and
+// the page is revalidated by an ETag changing with the file system tree.
+type allPackagesHandler struct {
+	pres *godoc.Presentation
+}
+
+func (h allPackagesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/all" {
+		http.NotFound(w, r)
+		return
+	}
+	etag := fmt.Sprintf(`"%x-%t"`, h.pres.Corpus.FSModifiedTime().UnixNano(), *showInternal)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var mode godoc.PageInfoMode
+	if *showInternal {
+		mode = godoc.NoFiltering
+	}
+	pkgs := listPackagesMode(h.pres, mode)
+
+	pageHeader, pageFooter, err := pageChrome(h.pres, godoc.Page{
+		Title:    "All Packages",
+		Tabtitle: "All Packages",
+	})
+	if err != nil {
+		log.Print("all packages: ", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+
+	w.Write(pageHeader)
+	fmt.Fprintf(w, "<p>%d packages</p>\n<table class=\"dir\">\n", len(pkgs))
+	for i, d := range pkgs {
+		p := html.EscapeString(d.Path)
+		io.WriteString(w, `<tr><td class="pkg-name"><a href="/pkg/`+p+`/">`+p+`</a></td><td class="pkg-synopsis">`+html.EscapeString(d.Synopsis)+"</td></tr>\n")
+		if flusher != nil && i%allPackagesFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+	io.WriteString(w, "</table>\n")
+	w.Write(pageFooter)
+}
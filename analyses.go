@@ -0,0 +1,73 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// analysisConfig is what analysis.Run should do.
+type analysisConfig struct {
+	typeAnalysis    bool
+	pointerAnalysis bool
+}
+
+func (c *analysisConfig) enabled() bool {
+	return c.typeAnalysis || c.pointerAnalysis
+}
+
+// analyses are the names accepted by -analysis.
+var analyses = map[string]struct {
+	doc    string
+	enable func(c *analysisConfig)
+}{
+	"type": {
+		"type analysis: identifier links, method sets, implements relation",
+		func(c *analysisConfig) { c.typeAnalysis = true },
+	},
+	"pointer": {
+		"pointer analysis (includes type analysis): call graph, channel peers, dynamic calls",
+		func(c *analysisConfig) { c.pointerAnalysis = true },
+	},
+}
+
+func analysisNames() []string {
+	var names []string
+	for name := range analyses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseAnalyses parses comma-separated list of analyses.
+func parseAnalyses(s string) (*analysisConfig, error) {
+	c := &analysisConfig{}
+	if s == "" {
+		return c, nil
+	}
+	if !analysisBuilt {
+		return nil, errors.New("-analysis isn't supported by this binary, built with the minimal tag")
+	}
+	for _, name := range strings.Split(s, ",") {
+		a, ok := analyses[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown analysis: %s (supported: %s)", name, strings.Join(analysisNames(), ", "))
+		}
+		a.enable(c)
+	}
+	return c, nil
+}
+
+// printAnalyses lists supported analyses, for -analysis=list.
+func printAnalyses(w io.Writer) {
+	for _, name := range analysisNames() {
+		fmt.Fprintf(w, "%s\t%s\n", name, analyses[name].doc)
+	}
+}
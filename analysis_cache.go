@@ -0,0 +1,218 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	rdebug "runtime/debug" // debug is the -debug flag
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// cachedPage is a page stored by analysisCache.
+type cachedPage struct {
+	Header http.Header
+	Code   int
+	Body   []byte
+}
+
+// analysisCache keeps package and source pages rendered with analysis
+// results in files in dir, so that they survive restarts:
+// analysis takes minutes, and socket activation restarts the server often.
+//
+// Pages are stored once the analysis has finished, and served from then
+// on, even by later processes still running their analysis, as long as
+// the sources are the same (see sourceStamp). Files not used recently are
+// removed once there are more than maxBytes of them.
+type analysisCache struct {
+	h        http.Handler
+	dir      string
+	maxBytes int64
+
+	stamp atomic.Value // string, set once computed
+
+	evictMu sync.Mutex
+}
+
+func newAnalysisCache(h http.Handler, dir string, maxBytes int64) *analysisCache {
+	c := &analysisCache{h: h, dir: dir, maxBytes: maxBytes}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Print("Analysis cache: ", err)
+		return c
+	}
+	go func() {
+		start := time.Now()
+		stamp, err := sourceStamp(fs, "/src")
+		if err != nil {
+			log.Print("Analysis cache disabled: ", err)
+			return
+		}
+		c.stamp.Store(stamp)
+		if *verbose {
+			log.Printf("Analysis cache: sources stamped in %v", time.Since(start))
+		}
+	}()
+	return c
+}
+
+func (c *analysisCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stamp, _ := c.stamp.Load().(string)
+	if stamp == "" || (r.Method != "GET" && r.Method != "HEAD") {
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	// The host is part of the key, as pages differ for .cn hosts.
+	sum := sha256.Sum256([]byte(stamp + "\x00" + r.Host + " " + r.URL.RequestURI()))
+	filename := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+
+	if page, err := c.load(filename); err == nil {
+		buf := newResponseBuffer()
+		for k, v := range page.Header {
+			buf.header[k] = v
+		}
+		buf.code = page.Code
+		buf.writeTo(w, page.Body)
+		return
+	} else if !os.IsNotExist(err) {
+		log.Print("Analysis cache: ", err)
+	}
+
+	if atomic.LoadInt32(&analysisPending) != 0 {
+		// the page lacks analysis results
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	buf := newResponseBuffer()
+	c.h.ServeHTTP(buf, r)
+	buf.writeTo(w, buf.body.Bytes())
+	if buf.status() == http.StatusOK && r.Method == "GET" && buf.isHTML() {
+		if err := c.store(filename, buf); err != nil {
+			log.Print("Analysis cache: ", err)
+		}
+	}
+}
+
+// load reads a stored page, marking it as recently used.
+func (c *analysisCache) load(filename string) (*cachedPage, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	page := &cachedPage{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(page); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	now := time.Now()
+	os.Chtimes(filename, now, now)
+	return page, nil
+}
+
+func (c *analysisCache) store(filename string, buf *responseBuffer) error {
+	var data bytes.Buffer
+	page := &cachedPage{Header: buf.header, Code: buf.status(), Body: buf.body.Bytes()}
+	if err := gob.NewEncoder(&data).Encode(page); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.dir, ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return c.evict()
+}
+
+// evict removes the least recently used files until at most maxBytes remain.
+func (c *analysisCache) evict() error {
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+	list, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, fi := range list {
+		total += fi.Size()
+	}
+	if total <= c.maxBytes {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ModTime().Before(list[j].ModTime())
+	})
+	for _, fi := range list {
+		if total <= c.maxBytes {
+			break
+		}
+		if strings.HasPrefix(fi.Name(), ".tmp") {
+			// being written
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, fi.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= fi.Size()
+	}
+	return nil
+}
+
+// sourceStamp hashes what the analysis results depend on: the analysis
+// requested, versions of Go and of this program, and names, sizes and
+// modification times of all files under root.
+func sourceStamp(fs vfs.FileSystem, root string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintln(h, *analysisFlag, runtime.Version())
+	if info, ok := rdebug.ReadBuildInfo(); ok {
+		fmt.Fprintln(h, info.Main.Version, info.Main.Sum)
+	}
+	if err := stampDir(h, fs, root); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func stampDir(h hash.Hash, fs vfs.FileSystem, dir string) error {
+	list, err := fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range list {
+		name := path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			if err := stampDir(h, fs, name); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(h, name, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return nil
+}
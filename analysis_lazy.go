@@ -0,0 +1,34 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// lazyAnalysis starts run in background upon the first request for
+// a package or source page, or for /api/implements, instead of at startup.
+//
+// This defers the analysis, it doesn't make it incremental: analysis.Run
+// loads and analyzes every package of GOROOT and GOPATH into the single
+// Result of the corpus, with no way to analyze a subset of packages or
+// merge results per import path. Per-package analysis would need its own
+// implementation of godoc/analysis, so -analysis_lazy only helps
+// instances that go unvisited for a while after starting.
+func lazyAnalysis(h http.Handler, run func()) http.Handler {
+	var once sync.Once
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/pkg/") || strings.HasPrefix(r.URL.Path, "/src/") || r.URL.Path == "/api/implements" {
+			once.Do(func() {
+				log.Printf("Starting analysis on first request for %s", r.URL.Path)
+				go run()
+			})
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,16 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build minimal
+
+package main
+
+import "golang.org/x/tools/godoc"
+
+// analysisBuilt tells whether -analysis is supported by this binary:
+// it isn't, as the binary was built with the minimal tag.
+const analysisBuilt = false
+
+// runTypeAnalysis is never called, as parseAnalyses rejects all analyses.
+func runTypeAnalysis(c *analysisConfig, corpus *godoc.Corpus) {}
@@ -0,0 +1,32 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"sync/atomic"
+)
+
+// analysisPending is 1 while analysis is enabled, but hasn't finished yet.
+var analysisPending int32
+
+var shortNavMarker = []byte(`<div id="short-nav">`)
+
+// analysisNoticeFilter tells that analysis results, such as implements relation
+// and call graph, are missing from the package page, because analysis is still running.
+func analysisNoticeFilter(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+	if atomic.LoadInt32(&analysisPending) == 0 {
+		return page
+	}
+	status := pres.Corpus.Analysis.Status()
+	if status == "" {
+		status = "Analysis hasn't started yet."
+	}
+	notice := fmt.Sprintf(`<p class="analysis-notice"><a href="/lib/godoc/analysis/help.html">Static analysis</a> is in progress, `+
+		`so this page lacks its results; reload later. <span style="color: grey">[%s]</span></p>`+"\n", html.EscapeString(status))
+	return injectBefore(page, shortNavMarker, []byte(notice))
+}
@@ -0,0 +1,20 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/analysis"
+)
+
+// analysisBuilt tells whether -analysis is supported by this binary.
+const analysisBuilt = true
+
+// runTypeAnalysis runs the analyses of c, storing results in corpus.
+func runTypeAnalysis(c *analysisConfig, corpus *godoc.Corpus) {
+	analysis.Run(c.pointerAnalysis, &corpus.Analysis)
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+func TestAPIErrors(t *testing.T) {
+	p := newTestPresentation(t, mapfs.New(map[string]string{
+		"src/p/p.go": "// Package p is a package.\npackage p\n\n// F does nothing.\nfunc F() {}\n",
+	}))
+	tests := []struct {
+		h    http.Handler
+		url  string
+		code int
+		msg  string
+	}{
+		{http.HandlerFunc(apiNotFound), "/api/nope", http.StatusNotFound, "not found"},
+		{symbolDocHandler{p}, "/api/doc?pkg=p", http.StatusBadRequest, "pkg and sym are required"},
+		{symbolDocHandler{p}, "/api/doc?pkg=q&sym=F", http.StatusNotFound, "package not found"},
+		{symbolDocHandler{p}, "/api/doc?pkg=p&sym=G", http.StatusNotFound, "symbol not found"},
+		{&importsHandler{pres: p}, "/api/imports", http.StatusBadRequest, "pkg is required"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.h.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.url, w.Code, tt.code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q, want application/json", tt.url, ct)
+		}
+		var resp struct{ Error string }
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: %v in %q", tt.url, err, w.Body)
+		} else if resp.Error != tt.msg {
+			t.Errorf("%s: error = %q, want %q", tt.url, resp.Error, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"golang.org/x/tools/godoc/vfs"
+	"golang.org/x/tools/godoc/vfs/gatefs"
+)
+
+type binding struct {
+	mount  string
+	fs     vfs.FileSystem
+	old    string
+	mode   vfs.BindMode
+	origin string
+}
+
+// bindings are all bindings of fs done with bind, in order.
+var bindings []binding
+
+// bind is fs.Bind, recorded for -print_bindings and -src_origin.
+// origin names the tree fsys belongs to, see originOf.
+func bind(mount string, fsys vfs.FileSystem, old string, mode vfs.BindMode, origin string) {
+	b := binding{mount, fsys, old, mode, origin}
+	bindings = append(bindings, b)
+	mountTable.bind(b)
+	fs.Bind(mount, fsys, old, mode)
+}
+
+// osDirs are the OS directories of file systems made by osFS.
+var osDirs = make(map[vfs.FileSystem]string)
+
+// osFS returns the OS file system rooted at dir (see tolerantFS), gated by gate (if not nil),
+// remembering dir for osPath. With -fsys=iofs, dir is read through os.DirFS instead.
+func osFS(dir string, gate chan bool) vfs.FileSystem {
+	var inner vfs.FileSystem = tolerantFS{vfs.OS(dir), dir}
+	if *fsysFlag == "iofs" {
+		inner = newIOFS(os.DirFS(dir), "iofs("+dir+")")
+	}
+	fsys := gatefs.New(inner, gate)
+	osDirs[fsys] = dir
+	return fsys
+}
+
+var bindModeNames = map[vfs.BindMode]string{
+	vfs.BindReplace: "replace",
+	vfs.BindBefore:  "before",
+	vfs.BindAfter:   "after",
+}
+
+// printBindings prints the recorded bindings, one per line.
+func printBindings(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "MOUNT\tSOURCE\tPATH\tMODE\tORIGIN")
+	for _, b := range bindings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.mount, b.fs, b.old, bindModeNames[b.mode], b.origin)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"text/template"
+)
+
+const stockSiteTitle = "The Go Programming Language"
+
+// brandingFuncs are template funcs for -site_title and -site_logo,
+// available to all templates.
+var brandingFuncs = template.FuncMap{
+	"site_title": func() string {
+		if *siteTitle == "" {
+			return stockSiteTitle
+		}
+		return *siteTitle
+	},
+	"site_logo": func() string { return *siteLogo },
+}
+
+var (
+	headingMarkers = [][]byte{
+		[]byte(`<div class="top-heading" id="heading-wide"><a href="/">`),
+		[]byte(`<div class="top-heading" id="heading-narrow"><a href="/">`),
+	}
+	logoHTML = []byte(`<img src="{{html site_logo}}" alt="" style="height: 1em; vertical-align: middle;"> `)
+)
+
+// brandTemplate makes stock template source use the -site_title and
+// -site_logo branding. Templates referring to site_title and site_logo
+// themselves are left alone.
+func brandTemplate(data []byte) []byte {
+	if bytes.Contains(data, []byte("site_title")) || bytes.Contains(data, []byte("site_logo")) {
+		return data
+	}
+	if *siteTitle != "" {
+		data = bytes.ReplaceAll(data, []byte(stockSiteTitle), []byte("{{html site_title}}"))
+	}
+	if *siteLogo != "" {
+		for _, m := range headingMarkers {
+			data = bytes.Replace(data, m, append(append([]byte(nil), m...), logoHTML...), 1)
+		}
+	}
+	return data
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"go/build"
+	"net/http"
+	"path/filepath"
+)
+
+// buildContextInfo is the JSON served by buildContextHandler.
+type buildContextInfo struct {
+	GOOS        string   `json:"goos"`
+	GOARCH      string   `json:"goarch"`
+	GOROOT      string   `json:"goroot"`
+	GOPATH      []string `json:"gopath"`
+	BuildTags   []string `json:"build_tags"`
+	ToolTags    []string `json:"tool_tags"`
+	ReleaseTags []string `json:"release_tags"`
+	CgoEnabled  bool     `json:"cgo_enabled"`
+	Compiler    string   `json:"compiler"`
+}
+
+// buildContextHandler serves /debug/buildcontext: build.Default, which
+// selects the files documented unless ?GOOS= and ?GOARCH= override it,
+// with -goos and -goarch applied. GOROOT is the served -goroot.
+func buildContextHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := build.Default
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(buildContextInfo{
+		GOOS:        ctx.GOOS,
+		GOARCH:      ctx.GOARCH,
+		GOROOT:      *goroot,
+		GOPATH:      filepath.SplitList(ctx.GOPATH),
+		BuildTags:   ctx.BuildTags,
+		ToolTags:    ctx.ToolTags,
+		ReleaseTags: ctx.ReleaseTags,
+		CgoEnabled:  ctx.CgoEnabled,
+		Compiler:    ctx.Compiler,
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// cachingFS remembers results of Stat, Lstat and ReadDir of an immutable
+// file system, like a zip file, for as long as the process lives.
+// Rendering a page does many of them, and zipfs ReadDir scans the archive
+// directory. Opened files aren't cached, and neither are errors, so that
+// requests for made-up paths don't grow the cache beyond the archive.
+type cachingFS struct {
+	vfs.FileSystem
+
+	mu     sync.RWMutex
+	stats  map[string]os.FileInfo
+	lstats map[string]os.FileInfo
+	dirs   map[string][]os.FileInfo
+}
+
+func newCachingFS(fs vfs.FileSystem) *cachingFS {
+	return &cachingFS{
+		FileSystem: fs,
+		stats:      make(map[string]os.FileInfo),
+		lstats:     make(map[string]os.FileInfo),
+		dirs:       make(map[string][]os.FileInfo),
+	}
+}
+
+func (c *cachingFS) Stat(name string) (os.FileInfo, error) {
+	return c.stat(c.stats, c.FileSystem.Stat, name)
+}
+
+func (c *cachingFS) Lstat(name string) (os.FileInfo, error) {
+	return c.stat(c.lstats, c.FileSystem.Lstat, name)
+}
+
+func (c *cachingFS) stat(cache map[string]os.FileInfo, stat func(string) (os.FileInfo, error), name string) (os.FileInfo, error) {
+	c.mu.RLock()
+	fi, ok := cache[name]
+	c.mu.RUnlock()
+	if ok {
+		return fi, nil
+	}
+	fi, err := stat(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	cache[name] = fi
+	c.mu.Unlock()
+	return fi, nil
+}
+
+func (c *cachingFS) ReadDir(name string) ([]os.FileInfo, error) {
+	c.mu.RLock()
+	list, ok := c.dirs[name]
+	c.mu.RUnlock()
+	if !ok {
+		var err error
+		list, err = c.FileSystem.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.dirs[name] = list
+		c.mu.Unlock()
+	}
+	// callers (like vfs.NameSpace) may sort the list in place
+	return append([]os.FileInfo(nil), list...), nil
+}
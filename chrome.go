@@ -0,0 +1,38 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"runtime"
+
+	"golang.org/x/tools/godoc"
+)
+
+const pageBodyMarker = "\x00body\x00"
+
+// pageChrome renders godoc.html around an empty page body, and returns
+// the parts before and after the body, so that the body itself can be streamed.
+func pageChrome(p *godoc.Presentation, page godoc.Page) (header, footer []byte, err error) {
+	// keep in sync with godoc.Presentation.ServePage
+	if page.Tabtitle == "" {
+		page.Tabtitle = page.Title
+	}
+	page.SearchBox = p.Corpus.IndexEnabled
+	page.Playground = p.ShowPlayground
+	page.Version = runtime.Version()
+	page.Body = []byte(pageBodyMarker)
+
+	var buf bytes.Buffer
+	if err := p.GodocHTML.Execute(&buf, page); err != nil {
+		return nil, nil, err
+	}
+	parts := bytes.SplitN(buf.Bytes(), []byte(pageBodyMarker), 2)
+	if len(parts) != 2 {
+		// the template doesn't render the body at all
+		return buf.Bytes(), nil, nil
+	}
+	return parts[0], parts[1], nil
+}
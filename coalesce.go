@@ -0,0 +1,36 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescingHandler lets concurrent identical GET requests share
+// a single render by h. The shared response is buffered in memory
+// and copied to each of them; headers set on w by outer handlers
+// are kept.
+type coalescingHandler struct {
+	h     http.Handler
+	group singleflight.Group
+}
+
+func (c *coalescingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	// The host is part of the key, as pages differ for .cn hosts.
+	key := r.Host + " " + r.URL.RequestURI()
+	v, _, _ := c.group.Do(key, func() (interface{}, error) {
+		buf := newResponseBuffer()
+		c.h.ServeHTTP(buf, r)
+		return buf, nil
+	})
+	buf := v.(*responseBuffer)
+	buf.writeTo(w, buf.body.Bytes())
+}
@@ -5,10 +5,11 @@
 package main
 
 import (
-	"encoding/json"
-	"go/format"
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 
@@ -31,13 +32,125 @@ func registerHandlers(pres *godoc.Presentation) *http.ServeMux {
 	mux := http.NewServeMux()
 	//mux.HandleFunc("/doc/codewalk/", codewalk)
 	mux.Handle("/doc/play/", pres.FileServer())
-	mux.Handle("/robots.txt", pres.FileServer())
-	mux.Handle("/", pres)
-	mux.Handle("/pkg/C/", redirect.Handler("/cmd/cgo/"))
-	mux.HandleFunc("/fmt", fmtHandler)
-	redirect.Register(mux)
+	if *robots != "" {
+		mux.Handle("/robots.txt", robotsHandler(robotsTxt()))
+	} else {
+		mux.Handle("/robots.txt", pres.FileServer())
+	}
+	if singleDirImportPath != "" {
+		mux.Handle("/", singleDirRootHandler{pres})
+	} else {
+		mux.Handle("/", pres)
+	}
+	mux.Handle("/healthz", &healthHandler{fs: fs, deep: *deepHealth})
+	mux.HandleFunc("/version", versionHandler)
+	mux.HandleFunc("/stats", statsHandler)
+	mux.Handle("/index.json", &indexJSONHandler{pres: pres})
+	mux.Handle("/sitemap.xml", sitemapHandler{pres: pres})
+	mux.Handle("/all", allPackagesHandler{pres: pres})
+	searchHandler := traced("search", pres)
+	if *searchTimeout > 0 {
+		searchHandler = &searchTimeoutHandler{h: searchHandler, timeout: *searchTimeout}
+	}
+	mux.Handle("/search", searchQueryGuard(searchHandler))
+	mux.HandleFunc("/api/", apiNotFound)
+	mux.Handle("/api/suggest", &suggestHandler{corpus: pres.Corpus})
+	mux.Handle("/api/doc", symbolDocHandler{pres})
+	mux.Handle("/api/implements", implementsHandler{pres.Corpus})
+	mux.Handle("/api/imports", &importsHandler{pres: pres})
+	var pkgFilters []pageFilter
+	if *analysisFlag != "" {
+		pkgFilters = append(pkgFilters, analysisNoticeFilter)
+	}
+	if *renderReadme {
+		pkgFilters = append(pkgFilters, readmeFilter)
+	}
+	if *verifyExamples {
+		pkgFilters = append(pkgFilters, examplesFilter)
+	}
+	var render *renderLimiter
+	if *renderConcurrency > 0 {
+		render = newRenderLimiter(*renderConcurrency, *renderWait)
+	}
+	var pkgHandler http.Handler = pres
+	if *pinStdlib {
+		pinned := newPinnedPages(pres)
+		go pinned.pin(stdlibPackages(listPackages(pres)), *pinStdlibMaxBytes)
+		pkgHandler = pinned
+	}
+	pkgHandler = traced("render", pkgHandler)
+	pkgHandler = fragmentHandler{h: pkgHandler, fragment: newFragmentPresentation(pres)}
+	pkgHandler = &pageFilterHandler{h: pkgHandler, filters: pkgFilters}
+	if render != nil {
+		// inside coalescingHandler, so that shared renders take one slot
+		pkgHandler = render.limit(pkgHandler)
+	}
+	if *analysisCacheDir != "" && *analysisFlag != "" {
+		pkgHandler = newAnalysisCache(pkgHandler, filepath.Join(*analysisCacheDir, "pkg"), *analysisCacheMaxBytes/2)
+	}
+	if *coalesceRenders {
+		pkgHandler = &coalescingHandler{h: pkgHandler}
+	}
+	if *canonicalHost != "" {
+		pkgHandler = canonicalLinkHandler{h: pkgHandler, host: *canonicalHost, basePath: *basePath}
+	}
+	pkgHandler = pkgViews.counting(pkgHandler)
+	if *recentSidebar {
+		pkgHandler = &pageFilterHandler{h: pkgHandler, filters: []pageFilter{recentFilter}}
+	}
+	if *canonicalQuery {
+		pkgHandler = queryAllowHandler{h: pkgHandler, basePath: *basePath}
+	}
+	mux.Handle("/pkg/", pkgHandler)
+
+	var srcHandler http.Handler = pres
+	if *streamDirlist || *srcOrigin || *maxDirlistEntries > 0 {
+		srcHandler = newDirlistHandler(pres, *srcOrigin, *maxDirlistEntries)
+	}
+	srcHandler = traced("render", srcHandler)
+	srcHandler = srcFilter(fs, srcHandler)
+	var srcFilters []pageFilter
+	if len(tabWidthMap) > 0 {
+		srcFilters = append(srcFilters, tabWidthFilter)
+	}
+	srcHandler = &pageFilterHandler{h: srcHandler, filters: srcFilters, skipDirs: true}
+	if render != nil {
+		srcHandler = render.limit(srcHandler)
+	}
+	if *analysisCacheDir != "" && *analysisFlag != "" {
+		srcHandler = newAnalysisCache(srcHandler, filepath.Join(*analysisCacheDir, "src"), *analysisCacheMaxBytes/2)
+	}
+	if *canonicalQuery {
+		srcHandler = queryAllowHandler{h: srcHandler, basePath: *basePath}
+	}
+	mux.Handle("/src/", srcHandler)
+
+	if *gitRepo != "" {
+		refs := newGitRefs(*gitRepo)
+		shutdowns.add("git worktrees", shutdownClose, 1, func(ctx context.Context, reason string) error {
+			return refs.cleanup(ctx)
+		})
+		var refHandler http.Handler = refs
+		if render != nil {
+			refHandler = render.limit(refHandler)
+		}
+		mux.Handle("/ref/", refHandler)
+	}
 
-	//http.Handle("/", hostEnforcerHandler{mux})
+	if *templateDir != "" && *templatesBrotli {
+		mux.Handle("/lib/godoc/", brotliHandler{h: pres, dir: *templateDir})
+	}
+	for _, m := range staticMounts {
+		mux.Handle(m.urlPath+"/", pres.FileServer())
+	}
+	mux.Handle("/dl/", newDownloadHandler(fsGateSize/2))
+	if *cgoRedirect != "" {
+		mux.Handle("/pkg/C/", redirect.Handler(*cgoRedirect))
+	}
+	if !*disableFmt {
+		registerFmtHandlers(mux)
+	}
+	redirect.Register(mux)
 
 	return mux
 }
@@ -52,12 +165,56 @@ func readTemplate(name string) *template.Template {
 	// (cannot use template ParseFile functions directly)
 	data, err := vfs.ReadFile(fs, path)
 	if err != nil {
-		log.Fatal("readTemplate: ", err)
+		fatal("readTemplate: ", err)
+	}
+	return parseTemplate(name, data)
+}
+
+// readOptionalTemplate is like readTemplate, but returns nil if the
+// template doesn't exist, e.g. in other x/tools versions.
+// godoc leaves out the parts of pages using such templates.
+func readOptionalTemplate(name string) *template.Template {
+	data, err := vfs.ReadFile(fs, "lib/godoc/"+name)
+	if os.IsNotExist(err) {
+		log.Printf("readTemplate: %s doesn't exist, skipping", name)
+		return nil
+	}
+	if err != nil {
+		fatal("readTemplate: ", err)
+	}
+	return parseTemplate(name, data)
+}
+
+func parseTemplate(name string, data []byte) *template.Template {
+	if *offline {
+		data = stripExternalLinks(data)
 	}
+	data = brandTemplate(data)
+	data = expandExamplesTemplate(data)
 	// be explicit with errors (for app engine use)
-	t, err := template.New(name).Funcs(pres.FuncMap()).Parse(string(data))
+	t := template.New(name).Funcs(pres.FuncMap()).Funcs(brandingFuncs).Funcs(examplesFuncs).Funcs(recentFuncs)
+	var commentHTML func(string) string // godoc's, wrapped by the options
+	if *highlightDeprecated {
+		commentHTML = deprecatedCommentHTML(commentHTMLFunc(pres, "highlight_deprecated"))
+	}
+	if ticketRx != nil {
+		if commentHTML == nil {
+			commentHTML = commentHTMLFunc(pres, "ticket_pattern")
+		}
+		commentHTML = ticketCommentHTML(commentHTML)
+	}
+	if commentHTML != nil {
+		t.Funcs(template.FuncMap{"comment_html": commentHTML})
+	}
+	if len(xrefMap) > 0 {
+		t.Funcs(xrefMap.funcs(pres))
+	}
+	if *showInternal {
+		t.Funcs(showInternalFuncs(pres))
+	}
+	t, err := t.Parse(string(data))
 	if err != nil {
-		log.Fatal("readTemplate: ", err)
+		fatal("readTemplate: ", err)
 	}
 	return t
 }
@@ -69,13 +226,13 @@ func readTemplates(p *godoc.Presentation, html bool) {
 	if html || p.HTMLMode {
 		//codewalkHTML = readTemplate("codewalk.html")
 		//codewalkdirHTML = readTemplate("codewalkdir.html")
-		p.CallGraphHTML = readTemplate("callgraph.html")
+		p.CallGraphHTML = readOptionalTemplate("callgraph.html")
 		p.DirlistHTML = readTemplate("dirlist.html")
 		p.ErrorHTML = readTemplate("error.html")
 		p.ExampleHTML = readTemplate("example.html")
 		p.GodocHTML = readTemplate("godoc.html")
-		p.ImplementsHTML = readTemplate("implements.html")
-		p.MethodSetHTML = readTemplate("methodset.html")
+		p.ImplementsHTML = readOptionalTemplate("implements.html")
+		p.MethodSetHTML = readOptionalTemplate("methodset.html")
 		p.PackageHTML = readTemplate("package.html")
 		p.SearchHTML = readTemplate("search.html")
 		p.SearchDocHTML = readTemplate("searchdoc.html")
@@ -85,25 +242,6 @@ func readTemplates(p *godoc.Presentation, html bool) {
 	}
 }
 
-type fmtResponse struct {
-	Body  string
-	Error string
-}
-
-// fmtHandler takes a Go program in its "body" form value, formats it with
-// standard gofmt formatting, and writes a fmtResponse as a JSON object.
-func fmtHandler(w http.ResponseWriter, r *http.Request) {
-	resp := new(fmtResponse)
-	body, err := format.Source([]byte(r.FormValue("body")))
-	if err != nil {
-		resp.Error = err.Error()
-	} else {
-		resp.Body = string(body)
-	}
-	w.Header().Set("Content-type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(resp)
-}
-
 // golang.org/x/tools/cmd/godoc/index.go
 func indexDirectoryDefault(dir string) bool {
 	return dir != "/pkg" && !strings.HasPrefix(dir, "/pkg/")
@@ -0,0 +1,57 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/godoc"
+)
+
+// initProgressInterval is how often initCorpus reports that it's still waiting.
+const initProgressInterval = 10 * time.Second
+
+// initCorpus runs c.Init, logging progress while it takes long.
+// If timeout is positive and Init doesn't finish in time, which usually means
+// one of the bound file systems stalls, it exits naming them.
+func initCorpus(c *godoc.Corpus, timeout time.Duration) {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Init()
+	}()
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	ticker := time.NewTicker(initProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				fatal(err)
+			}
+			return
+		case <-ticker.C:
+			log.Printf("Still reading the file system tree after %v", time.Since(start).Round(time.Second))
+		case <-deadline:
+			fatalf("Reading the file system tree didn't finish in -init_timeout=%v; one of these file systems is probably stuck: %s",
+				timeout, boundFileSystems())
+		}
+	}
+}
+
+// boundFileSystems lists the sources of all bindings, for error messages.
+func boundFileSystems() string {
+	var s []string
+	for _, b := range bindings {
+		s = append(s, b.mount+" <- "+b.fs.String())
+	}
+	return strings.Join(s, ", ")
+}
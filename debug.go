@@ -0,0 +1,26 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// debugAuthHandler requires requests to carry the -debug_token, if one is set.
+func debugAuthHandler(h http.Handler) http.Handler {
+	if *debugToken == "" {
+		return h
+	}
+	want := []byte("Bearer " + *debugToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,43 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+)
+
+const deprecatedPrefix = "Deprecated:"
+
+// deprecatedCommentHTML wraps the comment_html template func, rendering
+// "Deprecated:" paragraphs of doc comments as a callout. It's used for
+// package, type, function and value comments alike.
+func deprecatedCommentHTML(commentHTML func(string) string) func(string) string {
+	return func(comment string) string {
+		if !strings.Contains(comment, deprecatedPrefix) {
+			return commentHTML(comment)
+		}
+		var buf strings.Builder
+		var rest []string
+		flush := func() {
+			if len(rest) > 0 {
+				buf.WriteString(commentHTML(strings.Join(rest, "\n\n")))
+				rest = nil
+			}
+		}
+		for _, para := range strings.Split(comment, "\n\n") {
+			if !strings.HasPrefix(para, deprecatedPrefix) {
+				rest = append(rest, para)
+				continue
+			}
+			flush()
+			buf.WriteString(`<div class="deprecated" style="border-left: 4px solid #c33; background: #fdf0f0; padding: 0 0.5em; margin: 1em 0;">`)
+			buf.WriteString(`<span class="deprecated-badge" style="color: #fff; background: #c33; border-radius: 3px; padding: 0 0.3em; font-weight: bold;">Deprecated</span>`)
+			buf.WriteString(commentHTML(strings.TrimSpace(strings.TrimPrefix(para, deprecatedPrefix))))
+			buf.WriteString("</div>\n")
+		}
+		flush()
+		return buf.String()
+	}
+}
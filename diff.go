@@ -0,0 +1,206 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/printer"
+	"go/token"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+	"golang.org/x/tools/godoc/vfs/zipfs"
+)
+
+// newZipPresentation creates a separate corpus for the zip file,
+// laid out the same way as the -zip one (see zipBindings).
+func newZipPresentation(zipname string) *godoc.Presentation {
+	rc, err := openZip(zipname)
+	if err != nil {
+		fatalf("%s: %s\n", zipname, err)
+	}
+	var z vfs.FileSystem = zipfs.New(rc, zipname)
+	if *zipCache {
+		z = newCachingFS(z)
+	}
+	ns := vfs.NameSpace{}
+	for _, b := range zipBindings(z, zipname) {
+		ns.Bind(b.mount, b.fs, b.old, b.mode)
+	}
+
+	c := godoc.NewCorpus(ns)
+	c.Verbose = *verbose
+	if err := c.Init(); err != nil {
+		fatal(err)
+	}
+	return godoc.NewPresentation(c)
+}
+
+// apiDiffHandler serves a textual report of exported API changes
+// between the packages of the old and new corpora.
+type apiDiffHandler struct {
+	old, new *godoc.Presentation
+
+	once   sync.Once
+	report []byte
+}
+
+func newAPIDiffHandler(old, new *godoc.Presentation) *apiDiffHandler {
+	return &apiDiffHandler{old: old, new: new}
+}
+
+func (h *apiDiffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the corpora never change, so the report is computed only once
+	h.once.Do(func() {
+		h.report = apiDiff(h.old, h.new)
+	})
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(h.report)
+}
+
+func apiDiff(old, new *godoc.Presentation) []byte {
+	pkgs := make(map[string]bool)
+	oldPkgs := make(map[string]bool)
+	for _, d := range listPackages(old) {
+		pkgs[d.Path] = true
+		oldPkgs[d.Path] = true
+	}
+	newPkgs := make(map[string]bool)
+	for _, d := range listPackages(new) {
+		pkgs[d.Path] = true
+		newPkgs[d.Path] = true
+	}
+	var importPaths []string
+	for p := range pkgs {
+		importPaths = append(importPaths, p)
+	}
+	sort.Strings(importPaths)
+
+	var buf bytes.Buffer
+	for _, importPath := range importPaths {
+		switch {
+		case !oldPkgs[importPath]:
+			fmt.Fprintf(&buf, "package %s: added\n\n", importPath)
+			continue
+		case !newPkgs[importPath]:
+			fmt.Fprintf(&buf, "package %s: removed\n\n", importPath)
+			continue
+		}
+
+		oldSyms := packageSymbols(old, importPath)
+		newSyms := packageSymbols(new, importPath)
+		var changes []string
+		for _, name := range unionKeys(oldSyms, newSyms) {
+			oldDecl, inOld := oldSyms[name]
+			newDecl, inNew := newSyms[name]
+			switch {
+			case !inOld:
+				changes = append(changes, "+ "+newDecl)
+			case !inNew:
+				changes = append(changes, "- "+oldDecl)
+			case oldDecl != newDecl:
+				changes = append(changes, "- "+oldDecl, "+ "+newDecl)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "package %s: changed\n", importPath)
+		for _, c := range changes {
+			fmt.Fprintf(&buf, "\t%s\n", c)
+		}
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		buf.WriteString("no API changes\n")
+	}
+	return buf.Bytes()
+}
+
+// packageSymbols maps exported symbols of the package (methods as Type.Method)
+// to their declarations, with comments stripped.
+func packageSymbols(p *godoc.Presentation, importPath string) map[string]string {
+	info := p.GetPkgPageInfo(path.Join("/src", importPath), importPath, 0)
+	if info.Err != nil || info.PDoc == nil {
+		return nil
+	}
+	syms := make(map[string]string)
+	addValues := func(values []*doc.Value) {
+		for _, v := range values {
+			for _, spec := range v.Decl.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for _, name := range vs.Names {
+					if name.IsExported() {
+						syms[name.Name] = v.Decl.Tok.String() + " " + formatDecl(info.FSet, vs)
+					}
+				}
+			}
+		}
+	}
+	addFuncs := func(prefix string, funcs []*doc.Func) {
+		for _, f := range funcs {
+			syms[prefix+f.Name] = formatDecl(info.FSet, f.Decl)
+		}
+	}
+
+	pkg := info.PDoc
+	addValues(pkg.Consts)
+	addValues(pkg.Vars)
+	addFuncs("", pkg.Funcs)
+	for _, t := range pkg.Types {
+		for _, spec := range t.Decl.Specs {
+			if ts := spec.(*ast.TypeSpec); ts.Name.Name == t.Name {
+				syms[t.Name] = "type " + formatDecl(info.FSet, ts)
+			}
+		}
+		addValues(t.Consts)
+		addValues(t.Vars)
+		addFuncs("", t.Funcs)
+		addFuncs(t.Name+".", t.Methods)
+	}
+	return syms
+}
+
+// formatDecl prints node on a single line, without doc comments,
+// so that only the declarations themselves are compared.
+func formatDecl(fset *token.FileSet, node ast.Node) string {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			n.Doc = nil
+		case *ast.ValueSpec:
+			n.Doc, n.Comment = nil, nil
+		case *ast.TypeSpec:
+			n.Doc, n.Comment = nil, nil
+		case *ast.Field:
+			n.Doc, n.Comment = nil, nil
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, node)
+	return string(bytes.Join(bytes.Fields(buf.Bytes()), []byte(" ")))
+}
+
+func unionKeys(a, b map[string]string) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// dirlistFlushInterval is the number of rows written between flushes.
+const dirlistFlushInterval = 100
+
+// dirlistHandler serves /src/ directory listings incrementally,
+// instead of rendering the whole page before sending anything.
+// Everything else is passed to the presentation.
+type dirlistHandler struct {
+	p *godoc.Presentation
+
+	// origins tags entries with originOf
+	// and honors the ?origin= filter.
+	origins bool
+
+	// maxEntries limits the rows listed; 0 for no limit
+	maxEntries int
+
+	// dirlist.html split around its {{range .}} loop
+	header, row, footer *template.Template
+}
+
+func newDirlistHandler(p *godoc.Presentation, origins bool, maxEntries int) http.Handler {
+	data, err := vfs.ReadFile(fs, "lib/godoc/dirlist.html")
+	if err != nil {
+		fatal("readTemplate: ", err)
+	}
+	src := string(data)
+	start := strings.Index(src, "{{range .}}")
+	end := strings.LastIndex(src, "{{end}}")
+	if start < 0 || end < start {
+		log.Print("dirlist.html has no {{range .}} loop, directory listings won't be streamed")
+		return p
+	}
+
+	h := &dirlistHandler{p: p, origins: origins, maxEntries: maxEntries}
+	h.header = template.Must(template.New("dirlistHeader").Funcs(p.FuncMap()).Parse(src[:start]))
+	h.row = template.Must(template.New("dirlistRow").Funcs(p.FuncMap()).Parse(src[start+len("{{range .}}") : end]))
+	h.footer = template.Must(template.New("dirlistFooter").Funcs(p.FuncMap()).Parse(src[end+len("{{end}}"):]))
+	return h
+}
+
+func (h *dirlistHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	abspath := path.Clean(r.URL.Path)
+	relpath := r.URL.Path[1:]
+
+	// Leave redirects, index.html pages and errors to godoc.
+	fi, err := fs.Lstat(abspath)
+	if err != nil || !fi.IsDir() || !strings.HasSuffix(r.URL.Path, "/") {
+		h.p.ServeHTTP(w, r)
+		return
+	}
+	if _, err := fs.Stat(path.Join(abspath, "index.html")); err == nil {
+		h.p.ServeHTTP(w, r)
+		return
+	}
+	// The fsGate is held only during this call, not while rows are written.
+	list, err := fs.ReadDir(abspath)
+	if err != nil {
+		h.p.ServeHTTP(w, r)
+		return
+	}
+	if h.origins {
+		list = tagOrigins(abspath, list, r.FormValue("origin"))
+	}
+	total := len(list)
+	if h.maxEntries > 0 && total > h.maxEntries {
+		list = list[:h.maxEntries]
+	}
+
+	pageHeader, pageFooter, err := pageChrome(h.p, godoc.Page{
+		Title:    "Directory",
+		SrcPath:  relpath,
+		Tabtitle: relpath,
+	})
+	if err != nil {
+		log.Print("dirlist: ", err)
+		h.p.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+
+	w.Write(pageHeader)
+	if err := h.header.Execute(w, list); err != nil {
+		log.Print("dirlist: ", err)
+		return
+	}
+	for i, fi := range list {
+		if err := h.row.Execute(w, fi); err != nil {
+			log.Print("dirlist: ", err)
+			return
+		}
+		if flusher != nil && i%dirlistFlushInterval == 0 {
+			flusher.Flush()
+		}
+	}
+	if err := h.footer.Execute(w, list); err != nil {
+		log.Print("dirlist: ", err)
+		return
+	}
+	if len(list) < total {
+		h.writeTruncated(w, path.Base(abspath), len(list), total)
+	}
+	w.Write(pageFooter)
+}
+
+// writeTruncated writes the notice of a listing cut to -max_dirlist_entries,
+// with a link to search for the directory name if search is enabled.
+func (h *dirlistHandler) writeTruncated(w http.ResponseWriter, dir string, shown, total int) {
+	fmt.Fprintf(w, `<p class="dirlist-truncated">Listing truncated, %d of %d entries shown.`, shown, total)
+	if h.p.Corpus.IndexEnabled {
+		fmt.Fprintf(w, ` <a href="/search?q=%s">Search</a> for files instead.`, url.QueryEscape(dir))
+	}
+	fmt.Fprint(w, "</p>\n")
+}
+
+// tagOrigins wraps entries of dir into originFileInfo.
+// If only is not empty, only entries of that origin are kept.
+func tagOrigins(dir string, list []os.FileInfo, only string) []os.FileInfo {
+	tagged := make([]os.FileInfo, 0, len(list))
+	for _, fi := range list {
+		origin := originOf(path.Join(dir, fi.Name()))
+		if only != "" && origin != only {
+			continue
+		}
+		tagged = append(tagged, originFileInfo{fi, origin})
+	}
+	return tagged
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// downloadHandler serves /dl/<import path>.tar.gz: a tarball of the files
+// in the package directory (not including subdirectories), streamed as it's read.
+// Files hidden under /src/ by -src_extensions and -src_exclude are left out.
+// Range requests are answered from the tarball built in memory instead, so that
+// interrupted downloads can be resumed.
+type downloadHandler struct {
+	// limits concurrent downloads; kept apart from fsGate, since
+	// reading the files takes fsGate slots too
+	gate chan bool
+
+	allow, exclude map[string]bool // file extensions, as for srcExtensionFilter
+}
+
+func newDownloadHandler(n int) *downloadHandler {
+	return &downloadHandler{
+		gate:    make(chan bool, n),
+		allow:   parseExtensions(*srcExtensions),
+		exclude: parseExtensions(*srcExclude),
+	}
+}
+
+func (h *downloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, ".tar.gz") {
+		http.NotFound(w, r)
+		return
+	}
+	importPath := strings.Trim(path.Clean(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/dl/"), ".tar.gz")), "/")
+	if importPath == "" || importPath == "." {
+		http.NotFound(w, r)
+		return
+	}
+	dir := "/src/" + importPath
+	all, err := fs.ReadDir(dir)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	var list []os.FileInfo
+	for _, fi := range all {
+		if srcExtensionAllowed(fi.Name(), h.allow, h.exclude) {
+			list = append(list, fi)
+		}
+	}
+
+	select {
+	case h.gate <- true:
+		defer func() { <-h.gate }()
+	case <-r.Context().Done():
+		return
+	}
+
+	name := path.Base(importPath)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+	// the tarball is the same as long as the files are
+	modTime := latestModTime(list)
+	if r.Header.Get("Range") != "" {
+		var buf bytes.Buffer
+		if err := writeTarball(&buf, dir, name, list); err != nil {
+			log.Printf("Download of %s: %v", importPath, err)
+			http.Error(w, "reading package files failed", http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, name+".tar.gz", modTime, bytes.NewReader(buf.Bytes()))
+		return
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if err := writeTarball(w, dir, name, list); err != nil {
+		// the response has been started already, so just cut it short
+		log.Printf("Download of %s: %v", importPath, err)
+	}
+}
+
+// writeTarball writes a gzipped tarball of the regular files of list
+// in the vfs directory dir to w, under the directory name.
+func writeTarball(w io.Writer, dir, name string, list []os.FileInfo) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, fi := range list {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if err := addTarFile(tw, path.Join(dir, fi.Name()), path.Join(name, fi.Name())); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// latestModTime returns the latest modification time of regular files of list.
+func latestModTime(list []os.FileInfo) time.Time {
+	var t time.Time
+	for _, fi := range list {
+		if fi.Mode().IsRegular() && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t
+}
+
+// addTarFile writes the file at vfs path p to tw as name.
+func addTarFile(tw *tar.Writer, p, name string) error {
+	f, err := fs.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := fs.Stat(p)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.Mode = 0644
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, fi.Size())
+	return err
+}
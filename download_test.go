@@ -0,0 +1,118 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// bindTestFS replaces fs by a name space with fsys at the root
+// for the duration of the test.
+func bindTestFS(t *testing.T, fsys vfs.FileSystem) {
+	old := fs
+	fs = vfs.NameSpace{}
+	fs.Bind("/", fsys, "/", vfs.BindReplace)
+	t.Cleanup(func() { fs = old })
+}
+
+func TestDownloadRange(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "src/example.com/p/a.go", "package p\n")
+	writeTestFile(t, dir, "src/example.com/p/b.go", "package p\n\nfunc B() {}\n")
+	bindTestFS(t, vfs.OS(dir))
+	h := newDownloadHandler(1)
+
+	get := func(header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/dl/example.com/p.tar.gz", nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	full := get(nil)
+	if full.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", full.Code)
+	}
+	if got := full.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", got)
+	}
+	if got, want := full.Header().Get("Last-Modified"), testModTime.Format(http.TimeFormat); got != want {
+		t.Errorf("Last-Modified = %q, want %q", got, want)
+	}
+	tarball := full.Body.Bytes()
+
+	w := get(map[string]string{"Range": "bytes=10-29"})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("range: status = %d, want 206", w.Code)
+	}
+	if got, want := w.Header().Get("Content-Range"), fmt.Sprintf("bytes 10-29/%d", len(tarball)); got != want {
+		t.Errorf("range: Content-Range = %q, want %q", got, want)
+	}
+	if !bytes.Equal(w.Body.Bytes(), tarball[10:30]) {
+		t.Errorf("range: body differs from bytes 10-29 of the full tarball")
+	}
+
+	w = get(map[string]string{
+		"Range":    "bytes=10-29",
+		"If-Range": testModTime.Add(-time.Hour).Format(http.TimeFormat),
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("stale if-range: status = %d, want 200", w.Code)
+	}
+	if !bytes.Equal(w.Body.Bytes(), tarball) {
+		t.Errorf("stale if-range: body differs from the full tarball")
+	}
+}
+
+func TestDownloadExtensions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"p.go", "p.exe", "LICENSE", "key.pem", "sub/q.go"} {
+		writeTestFile(t, dir, "src/p/"+name, "data\n")
+	}
+	bindTestFS(t, vfs.OS(dir))
+
+	oldAllow, oldExclude := *srcExtensions, *srcExclude
+	t.Cleanup(func() { *srcExtensions, *srcExclude = oldAllow, oldExclude })
+	*srcExtensions, *srcExclude = ".,go,pem", "pem"
+	h := newDownloadHandler(1)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/dl/p.tar.gz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if want := []string{"p/LICENSE", "p/p.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("tarball has %q, want %q", names, want)
+	}
+}
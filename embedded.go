@@ -0,0 +1,30 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build embedded
+
+package main
+
+import (
+	"embed"
+	iofs "io/fs"
+	"log"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// embeddedFiles holds a Go root tree (the same layout as the -zip file
+// contents under -goroot) copied into the embedded directory before building.
+//
+//go:embed all:embedded
+var embeddedFiles embed.FS
+
+// embeddedFS returns the file system baked into the binary.
+func embeddedFS() vfs.FileSystem {
+	sub, err := iofs.Sub(embeddedFiles, "embedded")
+	if err != nil {
+		fatal(err)
+	}
+	return newIOFS(sub, "embedded")
+}
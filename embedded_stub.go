@@ -0,0 +1,14 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !embedded
+
+package main
+
+import "golang.org/x/tools/godoc/vfs"
+
+// embeddedFS returns nil, as the binary was built without the embedded tag.
+func embeddedFS() vfs.FileSystem {
+	return nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// flagEnvName returns the environment variable setting the named flag,
+// e.g. GODOC_INACTIVITY_TIMEOUT for -inactivity_timeout.
+func flagEnvName(name string) string {
+	return "GODOC_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// setFlagsFromEnv sets flags of fs from environment variables. It must be
+// called before fs.Parse, so that command line flags take precedence.
+// For repeatable flags, values from the command line are added to the one
+// from the environment.
+func setFlagsFromEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := flagEnvName(f.Name)
+		if v, ok := os.LookupEnv(name); ok && err == nil {
+			if serr := fs.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("%s: %v", name, serr)
+			}
+		}
+	})
+	return err
+}
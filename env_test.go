@@ -0,0 +1,57 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestSetFlagsFromEnv(t *testing.T) {
+	t.Setenv("GODOC_HTTP", ":7070")
+	t.Setenv("GODOC_INDEX", "true")
+	t.Setenv("GODOC_SEARCH_TIMEOUT", "5s")
+	t.Setenv("GODOC_STATIC_MOUNT", "/a=/srv/a")
+
+	flags := flag.NewFlagSet("godoc", flag.ContinueOnError)
+	httpAddr := flags.String("http", ":6060", "")
+	index := flags.Bool("index", false, "")
+	searchTimeout := flags.Duration("search_timeout", 0, "")
+	verbose := flags.Bool("v", false, "")
+	var mounts staticMountsFlag
+	flags.Var(&mounts, "static_mount", "")
+
+	if err := setFlagsFromEnv(flags); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Parse([]string{"-http=:8080", "-static_mount=/b=/srv/b"}); err != nil {
+		t.Fatal(err)
+	}
+	if *httpAddr != ":8080" {
+		t.Errorf("-http = %q, want the command line's :8080", *httpAddr)
+	}
+	if !*index {
+		t.Error("-index = false, want the environment's true")
+	}
+	if *searchTimeout != 5*time.Second {
+		t.Errorf("-search_timeout = %v, want the environment's 5s", *searchTimeout)
+	}
+	if *verbose {
+		t.Error("-v = true, want the default false")
+	}
+	if got := mounts.String(); got != "/a=/srv/a,/b=/srv/b" {
+		t.Errorf("-static_mount = %q, want the environment's then the command line's", got)
+	}
+}
+
+func TestSetFlagsFromEnvError(t *testing.T) {
+	t.Setenv("GODOC_INDEX", "maybe")
+	flags := flag.NewFlagSet("godoc", flag.ContinueOnError)
+	flags.Bool("index", false, "")
+	if err := setFlagsFromEnv(flags); err == nil {
+		t.Error("setFlagsFromEnv with GODOC_INDEX=maybe succeeded")
+	}
+}
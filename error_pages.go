@@ -0,0 +1,58 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"golang.org/x/tools/godoc"
+)
+
+// errorPageCodes are the status codes that may have an error template
+// of their own, errorNNN.html, e.g. provided in the -templates directory.
+var errorPageCodes = []int{
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusTooManyRequests,
+	http.StatusServiceUnavailable,
+}
+
+// errorPages holds the errorNNN.html templates found by readErrorPages.
+var errorPages = make(map[int]*template.Template)
+
+// readErrorPages reads those errorNNN.html templates that exist.
+func readErrorPages() {
+	for _, code := range errorPageCodes {
+		name := fmt.Sprintf("error%d.html", code)
+		if _, err := fs.Stat("/lib/godoc/" + name); err == nil {
+			errorPages[code] = readTemplate(name)
+		}
+	}
+}
+
+// serveErrorPage replies with code and a page showing msg, made of
+// errorNNN.html for the code if present, or error.html otherwise.
+// If neither can be rendered, msg is sent as plain text, like http.Error.
+func serveErrorPage(w http.ResponseWriter, code int, msg string) {
+	t := errorPages[code]
+	if t == nil && pres != nil {
+		t = pres.ErrorHTML
+	}
+	var body bytes.Buffer
+	if t == nil || t.Execute(&body, errors.New(msg)) != nil {
+		http.Error(w, msg, code)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	pres.ServePage(w, godoc.Page{
+		Title: http.StatusText(code),
+		Body:  body.Bytes(),
+	})
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exampleTestTimeout limits a single go test run of -verify_examples.
+const exampleTestTimeout = 2 * time.Minute
+
+// maxExampleRuns limits go test runs of -verify_examples at once.
+// Packages viewed while that many are running are verified on a later view.
+const maxExampleRuns = 2
+
+// exampleResultRx matches results of examples in go test -v output.
+var exampleResultRx = regexp.MustCompile(`^\s*--- (PASS|FAIL): Example(\w*) `)
+
+type exampleResults struct {
+	stamp   string          // see dirStamp
+	passed  map[string]bool // by example name without the Example prefix
+	running bool
+}
+
+// exampleVerifier runs examples of packages served from OS directories
+// with go test, in background, and remembers whether they passed
+// until the package files change.
+type exampleVerifier struct {
+	runs chan bool // limits runs in progress
+
+	mu      sync.Mutex
+	results map[string]*exampleResults // by directory
+}
+
+var examples = &exampleVerifier{
+	runs:    make(chan bool, maxExampleRuns),
+	results: make(map[string]*exampleResults),
+}
+
+// lookup returns the results for dir, or nil if they aren't known yet.
+// Unknown or outdated results are computed in background.
+func (v *exampleVerifier) lookup(dir string) map[string]bool {
+	stamp, err := dirStamp(dir)
+	if err != nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	res := v.results[dir]
+	if res == nil {
+		res = &exampleResults{}
+		v.results[dir] = res
+	}
+	if res.stamp == stamp {
+		return res.passed
+	}
+	if !res.running {
+		select {
+		case v.runs <- true:
+			res.running = true
+			go v.run(dir, stamp)
+		default:
+		}
+	}
+	return nil
+}
+
+func (v *exampleVerifier) run(dir, stamp string) {
+	passed, err := runExamples(dir)
+	<-v.runs
+	if err != nil {
+		log.Printf("Verifying examples in %s: %v", dir, err)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	res := v.results[dir]
+	res.running = false
+	res.stamp = stamp
+	res.passed = passed
+}
+
+// runExamples runs examples of the package in dir and reports which passed.
+func runExamples(dir string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(serverContext, exampleTestTimeout)
+	defer cancel()
+	goTool := filepath.Join(*goroot, "bin", "go")
+	if _, err := os.Stat(goTool); err != nil {
+		goTool = "go"
+	}
+	cmd := exec.CommandContext(ctx, goTool, "test", "-run=^Example", "-v", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	passed := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if m := exampleResultRx.FindStringSubmatch(sc.Text()); m != nil {
+			passed[m[2]] = m[1] == "PASS"
+		}
+	}
+	if len(passed) == 0 && err != nil {
+		// didn't build
+		return nil, err
+	}
+	return passed, nil
+}
+
+// dirStamp summarizes names and modification times of files in dir,
+// changing whenever the package might have changed.
+func dirStamp(dir string) (string, error) {
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, e := range list {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(e.Name())
+		b.WriteString(fi.ModTime().String())
+	}
+	return b.String(), nil
+}
+
+// examplesFilter marks examples on package pages as passing or failing.
+// Packages not served from OS directories (e.g. from -zip) are left alone,
+// as are pages whose examples haven't been run yet.
+func examplesFilter(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+	dir, ok := osPath(path.Join("/src", pkgImportPath(r.URL.Path)))
+	if !ok {
+		return page
+	}
+	passed := examples.lookup(dir)
+	for name, ok := range passed {
+		badge := `<span class="example-status" style="float: right; color: #c33;">✗ fails</span>`
+		if ok {
+			badge = `<span class="example-status" style="float: right; color: #375c00;">✓ passes</span>`
+		}
+		marker := exampleDiv(name)
+		page = bytes.Replace(page, []byte(marker), []byte(marker+badge), 1)
+	}
+	return page
+}
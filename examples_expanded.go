@@ -0,0 +1,41 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// examplesFuncs are template funcs for -examples_expanded,
+// available to all templates.
+var examplesFuncs = template.FuncMap{
+	"examples_expanded": func() bool { return *examplesExpanded },
+}
+
+var (
+	stockExampleDiv    = []byte(`<div id="example_{{.Name}}" class="toggle">`)
+	expandedExampleDiv = []byte(`<div id="example_{{.Name}}" class="{{if examples_expanded}}toggleVisible{{else}}toggle{{end}}">`)
+)
+
+// expandExamplesTemplate makes the stock example.html source start
+// expanded with -examples_expanded. godocs.js toggles elements of both
+// classes, so they can still be collapsed.
+func expandExamplesTemplate(data []byte) []byte {
+	if bytes.Contains(data, []byte("examples_expanded")) {
+		return data
+	}
+	return bytes.Replace(data, stockExampleDiv, expandedExampleDiv, 1)
+}
+
+// exampleDiv returns the opening tag of the example named name
+// on package pages.
+func exampleDiv(name string) string {
+	class := "toggle"
+	if *examplesExpanded {
+		class = "toggleVisible"
+	}
+	return `<div id="example_` + name + `" class="` + class + `">`
+}
@@ -0,0 +1,271 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"go/token"
+	"index/suffixarray"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/godoc"
+)
+
+// spotKindNames are the "spot" values of exported records, by godoc.SpotKind.
+var spotKindNames = []string{"package", "import", "const", "type", "var", "func", "method", "use"}
+
+func spotKindName(k godoc.SpotKind) string {
+	if int(k) < len(spotKindNames) {
+		return spotKindNames[k]
+	}
+	return "unknown"
+}
+
+// exportFileIndex is the part of the gob-encoded index (see godoc.Index.WriteTo)
+// exportIndex needs. It's decoded from the encoding because godoc.Index
+// doesn't give access to the words otherwise.
+type exportFileIndex struct {
+	Words    map[string]*godoc.LookupResult
+	Snippets []*godoc.Snippet
+	Fulltext bool
+	Idents   map[godoc.SpotKind]map[string][]godoc.Ident
+}
+
+// exportRecord is a line of -export_index output; which fields are set
+// depends on Kind, see README.
+type exportRecord struct {
+	Kind    string `json:"kind"` // "ident", "spot" or "text"
+	Spot    string `json:"spot,omitempty"`
+	Word    string `json:"word,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Package string `json:"package,omitempty"`
+	Doc     string `json:"doc,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Lines   []int  `json:"lines,omitempty"`
+}
+
+// exportIndex writes the current search index of c to filename
+// as newline-delimited JSON, in a deterministic order.
+func exportIndex(c *godoc.Corpus, filename string) error {
+	idx, _ := c.CurrentIndex()
+	if idx == nil {
+		return errors.New("no index has been built")
+	}
+	var buf bytes.Buffer
+	if _, err := idx.WriteTo(&buf); err != nil {
+		return err
+	}
+	r := bytes.NewReader(buf.Bytes())
+	var fx exportFileIndex
+	if err := gob.NewDecoder(r).Decode(&fx); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	err = exportIdents(enc, fx.Idents)
+	if err == nil {
+		err = exportSpots(enc, fx.Words, fx.Snippets)
+	}
+	if err == nil && fx.Fulltext {
+		err = exportText(enc, r)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// exportIdents writes "ident" records, ordered by kind, name and import path.
+func exportIdents(enc *json.Encoder, idents map[godoc.SpotKind]map[string][]godoc.Ident) error {
+	var kinds []int
+	for k := range idents {
+		kinds = append(kinds, int(k))
+	}
+	sort.Ints(kinds)
+	for _, k := range kinds {
+		byName := idents[godoc.SpotKind(k)]
+		var names []string
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			list := append([]godoc.Ident(nil), byName[name]...)
+			sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+			for _, id := range list {
+				err := enc.Encode(exportRecord{
+					Kind:    "ident",
+					Spot:    spotKindName(godoc.SpotKind(k)),
+					Word:    id.Name,
+					Path:    id.Path,
+					Package: id.Package,
+					Doc:     id.Doc,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// exportSpots writes "spot" records, occurrences of words in Go files,
+// ordered by word, then declarations before other uses, then by import
+// path, file and line.
+func exportSpots(enc *json.Encoder, words map[string]*godoc.LookupResult, snippets []*godoc.Snippet) error {
+	var list []string
+	for word := range words {
+		list = append(list, word)
+	}
+	sort.Strings(list)
+	for _, word := range list {
+		res := words[word]
+		for _, hits := range []godoc.HitList{res.Decls, res.Others} {
+			// the order of hits depends on the order files were indexed in
+			var recs []exportRecord
+			for _, pak := range hits {
+				for _, file := range pak.Files {
+					for _, run := range file.Groups {
+						for _, spot := range run {
+							line := spot.Lori()
+							if spot.IsIndex() {
+								line = 0
+								if i := spot.Lori(); i < len(snippets) {
+									line = snippets[i].Line
+								}
+							}
+							recs = append(recs, exportRecord{
+								Kind:    "spot",
+								Spot:    spotKindName(spot.Kind()),
+								Word:    word,
+								Path:    strings.TrimPrefix(pak.Pak.Path, "/src/"),
+								Package: pak.Pak.Name,
+								File:    file.File.Path(),
+								Line:    line,
+							})
+						}
+					}
+				}
+			}
+			sort.SliceStable(recs, func(i, j int) bool {
+				a, b := recs[i], recs[j]
+				if a.Path != b.Path {
+					return a.Path < b.Path
+				}
+				if a.File != b.File {
+					return a.File < b.File
+				}
+				return a.Line < b.Line
+			})
+			for _, rec := range recs {
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// exportText writes "text" records of the full-text index, read from r
+// following the gob-encoded part: for each indexed file, ordered by name,
+// its tokens (runs of letters, digits and underscores) in sorted order,
+// with the lines they occur on.
+func exportText(enc *json.Encoder, r *bytes.Reader) error {
+	fset := token.NewFileSet()
+	decode := func(x interface{}) error {
+		return gob.NewDecoder(r).Decode(x)
+	}
+	if err := fset.Read(decode); err != nil {
+		return err
+	}
+	var suffixes suffixarray.Index
+	if err := suffixes.Read(r); err != nil {
+		return err
+	}
+	// by construction of the index, token positions are offsets into
+	// the concatenated sources
+	data := suffixes.Bytes()
+
+	// files are indexed in no particular order
+	var files []*token.File
+	fset.Iterate(func(file *token.File) bool {
+		files = append(files, file)
+		return true
+	})
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	for _, file := range files {
+		base := file.Base()
+		if base+file.Size() > len(data) {
+			return errors.New("full-text index is inconsistent")
+		}
+		if err := exportFileTokens(enc, file.Name(), data[base:base+file.Size()]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportFileTokens(enc *json.Encoder, name string, src []byte) error {
+	lines := make(map[string][]int)
+	line := 1
+	for i := 0; i < len(src); {
+		ch, size := utf8.DecodeRune(src[i:])
+		if !isTokenRune(ch) {
+			if ch == '\n' {
+				line++
+			}
+			i += size
+			continue
+		}
+		start := i
+		for i < len(src) {
+			ch, size := utf8.DecodeRune(src[i:])
+			if !isTokenRune(ch) {
+				break
+			}
+			i += size
+		}
+		tok := string(src[start:i])
+		if l := lines[tok]; len(l) == 0 || l[len(l)-1] != line {
+			lines[tok] = append(l, line)
+		}
+	}
+	var toks []string
+	for tok := range lines {
+		toks = append(toks, tok)
+	}
+	sort.Strings(toks)
+	for _, tok := range toks {
+		if err := enc.Encode(exportRecord{Kind: "text", Word: tok, File: name, Lines: lines[tok]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isTokenRune(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+var exportTestFiles = map[string]string{
+	"src/a/a.go":   "// Package a is a.\npackage a\n\n// A is a func.\nfunc A() { B() }\n\n// B is another.\nfunc B() {}\n\nvar X, Y int\n",
+	"src/a/t.go":   "package a\n\n// T is a type.\ntype T struct{ F int }\n\nfunc (T) M() {}\n\nconst C = 1\n",
+	"src/b/b.go":   "// Package b uses a.\npackage b\n\nimport \"a\"\n\n// A calls a.A.\nfunc A() { a.A() }\n",
+	"src/b/c/c.go": "package c\n\nfunc A() {}\n",
+	"src/x.txt":    "not indexed\n",
+}
+
+// exportTestIndex builds the index of a fresh corpus of exportTestFiles
+// and exports it.
+func exportTestIndex(t *testing.T) []byte {
+	ns := vfs.NameSpace{}
+	ns.Bind("/", mapfs.New(exportTestFiles), "/", vfs.BindReplace)
+	c := godoc.NewCorpus(ns)
+	c.IndexEnabled = true
+	c.IndexFullText = true
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	c.UpdateIndex()
+	name := filepath.Join(t.TempDir(), "index.json")
+	if err := exportIndex(c, name); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestExportIndexDeterministic(t *testing.T) {
+	first := exportTestIndex(t)
+	// map iteration orders differ between runs,
+	// both while indexing and while exporting
+	for i := 0; i < 5; i++ {
+		if data := exportTestIndex(t); !bytes.Equal(data, first) {
+			t.Fatalf("export %d differs from the first one:\n%s\nvs.\n%s", i+1, data, first)
+		}
+	}
+
+	kinds := make(map[string]int)
+	for _, line := range bytes.Split(bytes.TrimSuffix(first, []byte("\n")), []byte("\n")) {
+		var rec exportRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("%v in %s", err, line)
+		}
+		kinds[rec.Kind]++
+	}
+	for _, kind := range []string{"ident", "spot", "text"} {
+		if kinds[kind] == 0 {
+			t.Errorf("no %s records in\n%s", kind, first)
+		}
+	}
+}
+
+func TestExportIndexNone(t *testing.T) {
+	c := godoc.NewCorpus(mapfs.New(exportTestFiles))
+	if err := exportIndex(c, filepath.Join(t.TempDir(), "index.json")); err == nil {
+		t.Error("exportIndex without an index succeeded")
+	}
+}
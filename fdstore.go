@@ -0,0 +1,79 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+
+	"github.com/coreos/go-systemd/activation"
+)
+
+// fdStoreName is the FDNAME the listener is stored under in systemd's fd store.
+const fdStoreName = "godoc-listener"
+
+// namedListener is a listener passed by systemd with its FDNAME.
+type namedListener struct {
+	net.Listener
+	name string
+
+	// selfBound is set for a listener the process bound itself in an
+	// earlier run and got back from the fd store, rather than one of
+	// a socket unit: nothing starts the service again if it exits.
+	selfBound bool
+}
+
+// activationListeners returns listeners passed by systemd, ordered by name.
+// If one of them comes from the fd store (see storeListener), only that one
+// is returned, marked as selfBound.
+func activationListeners() ([]namedListener, error) {
+	// read before ListenersWithNames unsets them
+	env := fmt.Sprintf("LISTEN_FDS=%q LISTEN_PID=%q LISTEN_FDNAMES=%q",
+		os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDNAMES"))
+	named, err := activation.ListenersWithNames(true)
+	if *logActivation {
+		logActivationListeners(env, named, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var listeners []namedListener
+	if stored := named[fdStoreName]; len(stored) > 0 {
+		for _, l := range stored {
+			listeners = append(listeners, namedListener{l, fdStoreName, true})
+		}
+		return listeners, nil
+	}
+	for name, ls := range named {
+		for _, l := range ls {
+			listeners = append(listeners, namedListener{l, name, false})
+		}
+	}
+	sort.SliceStable(listeners, func(i, j int) bool { return listeners[i].name < listeners[j].name })
+	return listeners, nil
+}
+
+// logActivationListeners logs the socket activation environment
+// and the listeners made of it, for -log_activation.
+func logActivationListeners(env string, named map[string][]net.Listener, err error) {
+	log.Printf("Socket activation: %s", env)
+	if err != nil {
+		log.Printf("Socket activation: %v", err)
+		return
+	}
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, l := range named[name] {
+			log.Printf("Socket activation: listener %q on %s %s", name, l.Addr().Network(), l.Addr())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// storeListener hands a copy of the listening socket over to systemd's fd store
+// (the service needs FileDescriptorStoreMax= set), so that the next start of the
+// service gets it back and doesn't have to bind it again.
+// It's done at startup rather than at exit, because server shutdown closes the listener.
+func storeListener(ln net.Listener) error {
+	notifySocket := os.Getenv("NOTIFY_SOCKET")
+	if notifySocket == "" {
+		return errors.New("NOTIFY_SOCKET is not set")
+	}
+	sc, ok := ln.(syscall.Conn)
+	if !ok {
+		return fmt.Errorf("can't get file descriptor of %T", ln)
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	sock, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(sock)
+	state := "FDSTORE=1\nFDNAME=" + fdStoreName
+	// not using (*net.TCPListener).File, as Fd of it would make the shared listener blocking
+	cerr := rc.Control(func(fd uintptr) {
+		err = syscall.Sendmsg(sock, []byte(state), syscall.UnixRights(int(fd)), &syscall.SockaddrUnix{Name: notifySocket}, 0)
+	})
+	if cerr != nil {
+		return cerr
+	}
+	return os.NewSyscallError("sendmsg", err)
+}
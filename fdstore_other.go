@@ -0,0 +1,17 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// storeListener fails: systemd's fd store is only available on Linux.
+func storeListener(ln net.Listener) error {
+	return errors.New("the fd store isn't supported on this platform")
+}
@@ -0,0 +1,60 @@
+// Copyright 2010 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"go/format"
+	"net/http"
+)
+
+// registerFmtHandlers registers the code formatting endpoints.
+func registerFmtHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/fmt", fmtHandler)
+	mux.HandleFunc("/api/fmt/batch", fmtBatchHandler)
+}
+
+// golang.org/x/tools/cmd/godoc/handlers.go
+
+type fmtResponse struct {
+	Body  string
+	Error string
+}
+
+// fmtHandler takes a Go program in its "body" form value, formats it with
+// standard gofmt formatting, and writes a fmtResponse as a JSON object.
+func fmtHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkFmtMethod(w, r) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFmtBodySize)
+	if err := r.ParseForm(); err != nil {
+		writeFmtResponse(w, http.StatusBadRequest, &fmtResponse{Error: err.Error()})
+		return
+	}
+	if _, ok := r.PostForm["body"]; !ok {
+		writeFmtResponse(w, http.StatusBadRequest, &fmtResponse{Error: "missing body"})
+		return
+	}
+	resp := formatSource([]byte(r.PostFormValue("body")))
+	writeFmtResponse(w, http.StatusOK, resp)
+}
+
+// formatSource formats src with standard gofmt formatting.
+func formatSource(src []byte) *fmtResponse {
+	resp := new(fmtResponse)
+	if len(src) > maxFmtBodySize {
+		resp.Error = errFmtBodyTooLarge.Error()
+		return resp
+	}
+	body, err := format.Source(src)
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Body = string(body)
+	}
+	return resp
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	maxFmtBodySize      = 1 << 20 // max size of a single program passed to /fmt
+	maxFmtBatchBodySize = 8 << 20 // max size of the whole /api/fmt/batch request
+)
+
+var errFmtBodyTooLarge = errors.New("program too large")
+
+type fmtBatchRequest struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+type fmtBatchResponse struct {
+	Name string
+	*fmtResponse
+}
+
+// fmtBatchHandler takes a JSON array of {name, body} objects, formats each body
+// like fmtHandler does, and writes a JSON array of results in the same order.
+func fmtBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkFmtMethod(w, r) {
+		return
+	}
+	var reqs []fmtBatchRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFmtBatchBodySize)).Decode(&reqs)
+	if err != nil {
+		writeFmtResponse(w, http.StatusBadRequest, &fmtResponse{Error: "malformed batch request: " + err.Error()})
+		return
+	}
+
+	resps := make([]fmtBatchResponse, len(reqs))
+	for i, req := range reqs {
+		resps[i] = fmtBatchResponse{
+			Name:        req.Name,
+			fmtResponse: formatSource([]byte(req.Body)),
+		}
+	}
+	writeFmtResponse(w, http.StatusOK, resps)
+}
+
+// checkFmtMethod rejects anything but POST requests to the formatting endpoints.
+func checkFmtMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == "POST" {
+		return true
+	}
+	w.Header().Set("Allow", "POST")
+	writeFmtResponse(w, http.StatusMethodNotAllowed, &fmtResponse{Error: "method not allowed"})
+	return false
+}
+
+func writeFmtResponse(w http.ResponseWriter, code int, resp interface{}) {
+	w.Header().Set("Content-type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
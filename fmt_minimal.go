@@ -0,0 +1,16 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build minimal
+
+package main
+
+import "net/http"
+
+// registerFmtHandlers registers 404 handlers for the code formatting
+// endpoints, as the binary was built with the minimal tag.
+func registerFmtHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/fmt", http.NotFound)
+	mux.HandleFunc("/api/fmt/batch", http.NotFound)
+}
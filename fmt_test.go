@@ -0,0 +1,77 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFmtHandler(t *testing.T) {
+	tests := []struct {
+		method string
+		form   url.Values
+		code   int
+		want   fmtResponse
+	}{
+		{"GET", nil, http.StatusMethodNotAllowed, fmtResponse{Error: "method not allowed"}},
+		{"POST", nil, http.StatusBadRequest, fmtResponse{Error: "missing body"}},
+		{"POST", url.Values{"body": {"package p\nfunc  f( ) {}"}}, http.StatusOK, fmtResponse{Body: "package p\n\nfunc f() {}\n"}},
+		{"POST", url.Values{"body": {"package p\nfunc {"}}, http.StatusOK, fmtResponse{Error: "2:6: expected 'IDENT', found '{'"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/fmt", strings.NewReader(tt.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		fmtHandler(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%s %v: status = %d, want %d", tt.method, tt.form, w.Code, tt.code)
+		}
+		var got fmtResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s %v: %v in %q", tt.method, tt.form, err, w.Body)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %v: got %+v, want %+v", tt.method, tt.form, got, tt.want)
+		}
+	}
+}
+
+func TestFmtBatchHandler(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		code   int
+		want   string
+	}{
+		{"GET", "", http.StatusMethodNotAllowed, `{"Body":"","Error":"method not allowed"}`},
+		{"POST", "", http.StatusBadRequest, `{"Body":"","Error":"malformed batch request: EOF"}`},
+		{"POST", "[]", http.StatusOK, `[]`},
+		{
+			"POST", `[{"name":"a","body":"package a\nvar  x=1"},{"name":"b","body":"package"}]`, http.StatusOK,
+			`[{"Name":"a","Body":"package a\n\nvar x = 1\n","Error":""},{"Name":"b","Body":"","Error":"1:8: expected 'IDENT', found 'EOF'"}]`,
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		fmtBatchHandler(w, httptest.NewRequest(tt.method, "/api/fmt/batch", strings.NewReader(tt.body)))
+		if w.Code != tt.code {
+			t.Errorf("%s %q: status = %d, want %d", tt.method, tt.body, w.Code, tt.code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s %q: Content-Type = %q", tt.method, tt.body, ct)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+			t.Errorf("%s %q: got %s, want %s", tt.method, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// stylesheetRx matches stylesheet links of godoc.html.
+var stylesheetRx = regexp.MustCompile(`<link[^>]*rel="stylesheet"[^>]*>`)
+
+// newFragmentPresentation returns a presentation sharing the corpus and
+// settings of p, whose pages are only the content (e.g. package.html output)
+// preceded by the stylesheet links of godoc.html, without header, navigation and footer.
+func newFragmentPresentation(p *godoc.Presentation) *godoc.Presentation {
+	fp := godoc.NewPresentation(p.Corpus)
+	fp.TabWidth = p.TabWidth
+	fp.ShowTimestamps = p.ShowTimestamps
+	fp.ShowPlayground = p.ShowPlayground
+	fp.DeclLinks = p.DeclLinks
+	fp.NotesRx = p.NotesRx
+	fp.AdjustPageInfoMode = p.AdjustPageInfoMode
+	readTemplates(fp, true)
+
+	data, err := vfs.ReadFile(fs, "lib/godoc/godoc.html")
+	if err != nil {
+		fatal("readTemplate: ", err)
+	}
+	links := stylesheetRx.FindAllString(string(data), -1)
+	src := strings.Join(links, "\n") + "\n" + `{{printf "%s" .Body}}` + "\n"
+	fp.GodocHTML = template.Must(template.New("fragment").Parse(src))
+	return fp
+}
+
+// fragmentHandler serves requests with ?fragment=1 from the fragment
+// presentation, and everything else from h.
+type fragmentHandler struct {
+	h        http.Handler
+	fragment *godoc.Presentation
+}
+
+func (h fragmentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("fragment") != "" {
+		h.fragment.ServeHTTP(w, r)
+		return
+	}
+	h.h.ServeHTTP(w, r)
+}
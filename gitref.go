@@ -0,0 +1,274 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// maxGitTrees limits worktrees kept by gitRefs; the least recently
+// used one is removed to check out another.
+const maxGitTrees = 8
+
+// maxGitCheckouts limits worktrees being checked out at once; requests
+// for more commits get 503 until one of them is done.
+const maxGitCheckouts = 2
+
+// gitTree is a worktree of a commit, with its own corpus.
+type gitTree struct {
+	once sync.Once
+	dir  string
+	pres *godoc.Presentation
+	src  http.Handler // pres with the /src/ filters of the server
+	err  error
+	used time.Time
+}
+
+// gitRefs serves /ref/<ref>/pkg/... and /ref/<ref>/src/... pages of the
+// repository as of ref (anything git rev-parse accepts without slashes,
+// e.g. a commit hash or a branch name), redirecting to the commit hash.
+// Each commit is checked out into a worktree in a temporary directory,
+// bound at the import path of the repository (see dirImportPath)
+// over the file system of the server.
+type gitRefs struct {
+	repo       string
+	importPath string
+
+	checkouts chan bool // limits checkouts in progress
+
+	mu    sync.Mutex
+	dir   string // temporary directory of the worktrees, once created
+	trees map[string]*gitTree
+}
+
+func newGitRefs(repo string) *gitRefs {
+	repo, err := filepath.Abs(repo)
+	if err != nil {
+		fatal(err)
+	}
+	return &gitRefs{
+		repo:       repo,
+		importPath: dirImportPath(repo),
+		checkouts:  make(chan bool, maxGitCheckouts),
+		trees:      make(map[string]*gitTree),
+	}
+}
+
+func (g *gitRefs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/ref/")
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		http.Redirect(w, r, r.URL.Path+"/", http.StatusFound)
+		return
+	}
+	ref, sub := rest[:i], rest[i:]
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		serveErrorPage(w, http.StatusNotFound, "bad ref")
+		return
+	}
+	commit, err := g.git(r.Context(), "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		serveErrorPage(w, http.StatusNotFound, "unknown ref "+ref)
+		return
+	}
+	if commit != ref {
+		u := "/ref/" + commit + sub
+		if r.URL.RawQuery != "" {
+			u += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+		return
+	}
+	if sub == "/" {
+		http.Redirect(w, r, "/ref/"+commit+"/pkg/"+g.importPath+"/", http.StatusFound)
+		return
+	}
+	if !strings.HasPrefix(sub, "/pkg/") && !strings.HasPrefix(sub, "/src/") {
+		serveErrorPage(w, http.StatusNotFound, "not found")
+		return
+	}
+	t := g.tree(commit)
+	if t == nil {
+		w.Header().Set("Retry-After", "5")
+		serveErrorPage(w, http.StatusServiceUnavailable, "other commits are being checked out, retry later")
+		return
+	}
+	if t.err != nil {
+		log.Printf("Checking out %s: %v", commit, t.err)
+		serveErrorPage(w, http.StatusServiceUnavailable, "couldn't check out "+commit)
+		return
+	}
+
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = sub
+	buf := newResponseBuffer()
+	if strings.HasPrefix(sub, "/src/") {
+		t.src.ServeHTTP(buf, r2)
+	} else {
+		t.pres.ServeHTTP(buf, r2)
+	}
+	body := buf.body.Bytes()
+	if buf.isHTML() {
+		// keep links to the repository's packages in this commit
+		prefix := []byte("/ref/" + commit)
+		for _, p := range []string{"/pkg/", "/src/"} {
+			old := []byte(`href="` + p + g.importPath)
+			body = bytes.ReplaceAll(body, old, append(append([]byte(`href="`), prefix...), old[len(`href="`):]...))
+		}
+	}
+	buf.writeTo(w, body)
+}
+
+// git runs git in the repository and returns its trimmed output.
+func (g *gitRefs) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.repo}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], bytes.TrimSpace(ee.Stderr))
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// tree returns the worktree of commit, checking it out if needed,
+// or nil if there are maxGitCheckouts checkouts in progress already.
+func (g *gitRefs) tree(commit string) *gitTree {
+	g.mu.Lock()
+	t := g.trees[commit]
+	if t == nil {
+		select {
+		case g.checkouts <- true:
+		default:
+			g.mu.Unlock()
+			return nil
+		}
+		t = &gitTree{}
+		g.trees[commit] = t
+		g.evictLocked(commit)
+		go func() {
+			t.once.Do(func() {}) // wait for the checkout
+			<-g.checkouts
+		}()
+	}
+	t.used = time.Now()
+	g.mu.Unlock()
+
+	t.once.Do(func() {
+		t.dir, t.pres, t.src, t.err = g.checkout(commit)
+		if t.err != nil {
+			// try again with the next request
+			g.mu.Lock()
+			if g.trees[commit] == t {
+				delete(g.trees, commit)
+			}
+			g.mu.Unlock()
+		}
+	})
+	return t
+}
+
+// evictLocked removes the least recently used worktree other than keep
+// if there are too many. g.mu must be held.
+func (g *gitRefs) evictLocked(keep string) {
+	if len(g.trees) <= maxGitTrees {
+		return
+	}
+	var oldest string
+	for commit, t := range g.trees {
+		if commit != keep && (oldest == "" || t.used.Before(g.trees[oldest].used)) {
+			oldest = commit
+		}
+	}
+	t := g.trees[oldest]
+	delete(g.trees, oldest)
+	go func() {
+		t.once.Do(func() {}) // wait for the checkout
+		if t.dir != "" {
+			g.remove(context.Background(), t.dir)
+		}
+	}()
+}
+
+// checkout adds a worktree of commit and makes a presentation of it,
+// and a /src/ handler of the presentation, filtered like the server's.
+func (g *gitRefs) checkout(commit string) (string, *godoc.Presentation, http.Handler, error) {
+	g.mu.Lock()
+	if g.dir == "" {
+		dir, err := ioutil.TempDir("", "godoc-ref")
+		if err != nil {
+			g.mu.Unlock()
+			return "", nil, nil, err
+		}
+		g.dir = dir
+	}
+	dir := filepath.Join(g.dir, commit)
+	g.mu.Unlock()
+
+	start := time.Now()
+	if _, err := g.git(serverContext, "worktree", "add", "--detach", dir, commit); err != nil {
+		return "", nil, nil, err
+	}
+	ns := vfs.NameSpace{}
+	// everything else, like the standard library, is as served at HEAD
+	ns.Bind("/", fs, "/", vfs.BindReplace)
+	ns.Bind("/src/"+g.importPath, vfs.OS(dir), "/", vfs.BindReplace)
+	c := godoc.NewCorpus(ns)
+	c.Verbose = *verbose
+	if err := c.Init(); err != nil {
+		g.remove(context.Background(), dir)
+		return "", nil, nil, err
+	}
+	p := godoc.NewPresentation(c)
+	p.TabWidth = pres.TabWidth
+	p.ShowTimestamps = pres.ShowTimestamps
+	p.ShowPlayground = pres.ShowPlayground
+	p.DeclLinks = pres.DeclLinks
+	p.NotesRx = pres.NotesRx
+	readTemplates(p, true)
+	log.Printf("Checked out %s in %v", commit, time.Since(start))
+	return dir, p, srcFilter(ns, p), nil
+}
+
+// remove removes the worktree in dir.
+func (g *gitRefs) remove(ctx context.Context, dir string) {
+	if _, err := g.git(ctx, "worktree", "remove", "--force", dir); err != nil {
+		log.Print("Removing worktree: ", err)
+		os.RemoveAll(dir)
+	}
+}
+
+// cleanup removes all worktrees, at shutdown. Checkouts in progress
+// have been cancelled along with serverContext.
+func (g *gitRefs) cleanup(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(g.dir); err != nil {
+		return err
+	}
+	g.trees = make(map[string]*gitTree)
+	g.dir = ""
+	// forget the removed worktrees
+	_, err := g.git(ctx, "worktree", "prune")
+	return err
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// healthCacheTime is how long a deep health check result is reused.
+const healthCacheTime = 5 * time.Second
+
+// healthHandler serves /healthz. With deep set, it also checks
+// that the served file system is still readable, which catches
+// a vanished zip file or an unavailable network-mounted GOPATH.
+type healthHandler struct {
+	fs   vfs.FileSystem
+	deep bool
+
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.deep {
+		if err := h.check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+func (h *healthHandler) check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.checked) < healthCacheTime {
+		return h.err
+	}
+	list, err := h.fs.ReadDir("/src")
+	if err == nil && len(list) == 0 {
+		err = errors.New("/src is empty")
+	}
+	h.checked = time.Now()
+	h.err = err
+	return err
+}
@@ -0,0 +1,34 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/static"
+	"golang.org/x/tools/godoc/vfs"
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+// newTestPresentation replaces fs and pres for the duration of the test
+// by a name space with root as the Go root and the stock templates,
+// and a presentation of its initialized corpus.
+func newTestPresentation(t *testing.T, root vfs.FileSystem) *godoc.Presentation {
+	t.Helper()
+	oldFS, oldPres := fs, pres
+	t.Cleanup(func() { fs, pres = oldFS, oldPres })
+	fs = vfs.NameSpace{}
+	fs.Bind("/", root, "/", vfs.BindReplace)
+	fs.Bind("/lib/godoc", mapfs.New(static.Files), "/", vfs.BindReplace)
+
+	c := godoc.NewCorpus(fs)
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	pres = godoc.NewPresentation(c)
+	readTemplates(pres, true)
+	return pres
+}
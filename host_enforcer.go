@@ -0,0 +1,58 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hostEnforcerHandler redirects requests for any host other than the
+// canonical one to the same URL on the canonical host.
+// Health, version, stats and debug endpoints are left alone, so probes using
+// the bare IP address keep working.
+type hostEnforcerHandler struct {
+	h        http.Handler
+	host     string
+	basePath string
+}
+
+func (h hostEnforcerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.EqualFold(r.Host, h.host) || r.URL.Path == "/healthz" || r.URL.Path == "/version" || r.URL.Path == "/stats" || strings.HasPrefix(r.URL.Path, "/debug/") {
+		h.h.ServeHTTP(w, r)
+		return
+	}
+	u := *r.URL
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	u.Host = h.host
+	u.Path = strings.TrimSuffix(h.basePath, "/") + u.Path
+	u.RawPath = ""
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
+
+// canonicalLinkHandler adds a Link header pointing at the page URL on the canonical host.
+type canonicalLinkHandler struct {
+	h        http.Handler
+	host     string
+	basePath string
+}
+
+func (h canonicalLinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   h.host,
+		Path:   strings.TrimSuffix(h.basePath, "/") + r.URL.Path,
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"canonical\"", u.String()))
+	h.h.ServeHTTP(w, r)
+}
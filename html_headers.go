@@ -0,0 +1,111 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/godoc"
+)
+
+// defaultCSP allows what godoc pages need: inline scripts and styles,
+// everything else from the same origin only.
+const defaultCSP = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:"
+
+// htmlHeaderHandler adds headers to HTML responses of h,
+// leaving other responses (JSON, plain text, files) alone.
+type htmlHeaderHandler struct {
+	h       http.Handler
+	headers http.Header
+}
+
+func (h *htmlHeaderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.h.ServeHTTP(&htmlHeaderWriter{ResponseWriter: w, headers: h.headers}, r)
+}
+
+// htmlHeaderWriter adds headers once it knows the response is HTML,
+// that is right before the response header is written.
+type htmlHeaderWriter struct {
+	http.ResponseWriter
+	headers     http.Header
+	wroteHeader bool
+}
+
+func (w *htmlHeaderWriter) addHeaders(body []byte) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	ct := w.Header().Get("Content-Type")
+	if ct == "" && body != nil {
+		// what net/http would do
+		ct = http.DetectContentType(body)
+	}
+	if !strings.HasPrefix(ct, "text/html") {
+		return
+	}
+	for k, v := range w.headers {
+		// append, e.g. to Link rel=canonical of canonicalLinkHandler
+		w.Header()[k] = append(w.Header()[k], v...)
+	}
+}
+
+func (w *htmlHeaderWriter) WriteHeader(code int) {
+	w.addHeaders(nil)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *htmlHeaderWriter) Write(p []byte) (int, error) {
+	w.addHeaders(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *htmlHeaderWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// pageHeaders returns headers to add to HTML pages, according to flags.
+func pageHeaders() http.Header {
+	h := make(http.Header)
+	if *csp != "" {
+		h.Set("Content-Security-Policy", *csp)
+	}
+	if *preload {
+		for _, link := range preloadLinks(pres) {
+			h.Add("Link", link)
+		}
+	}
+	return h
+}
+
+var (
+	stylesheetHrefRx = regexp.MustCompile(`<link[^>]*rel="stylesheet"[^>]*href="(/[^"]*)"`)
+	scriptSrcRx      = regexp.MustCompile(`<script[^>]*src="(/[^"]*)"`)
+)
+
+// preloadLinks returns Link rel=preload header values for the local
+// stylesheets and scripts the page chrome of p references.
+func preloadLinks(p *godoc.Presentation) []string {
+	header, footer, err := pageChrome(p, godoc.Page{})
+	if err != nil {
+		log.Print("Finding assets to preload: ", err)
+		return nil
+	}
+	chrome := string(header) + string(footer)
+	var links []string
+	for _, m := range stylesheetHrefRx.FindAllStringSubmatch(chrome, -1) {
+		links = append(links, fmt.Sprintf("<%s>; rel=preload; as=style", m[1]))
+	}
+	for _, m := range scriptSrcRx.FindAllStringSubmatch(chrome, -1) {
+		links = append(links, fmt.Sprintf("<%s>; rel=preload; as=script", m[1]))
+	}
+	return links
+}
@@ -0,0 +1,23 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"os/exec"
+)
+
+// runIdleHook runs command with /bin/sh, logging its output.
+// The command is killed once ctx is done, so it can't block shutdown.
+func runIdleHook(ctx context.Context, command string) {
+	out, err := exec.CommandContext(ctx, "/bin/sh", "-c", command).CombinedOutput()
+	if len(out) > 0 {
+		log.Printf("on_idle_exec output:\n%s", out)
+	}
+	if err != nil {
+		log.Print("on_idle_exec failed: ", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// idleWarning is the payload posted to -idle_warn_webhook.
+type idleWarning struct {
+	Event    string  `json:"event"`
+	Hostname string  `json:"hostname"`
+	Address  string  `json:"address"`
+	Seconds  float64 `json:"shutdown_in_seconds"`
+}
+
+var idleWarnClient = &http.Client{Timeout: 10 * time.Second}
+
+// postIdleWarning tells the webhook that the server is about to idle out.
+func postIdleWarning(url, addr string, lead time.Duration) {
+	hostname, _ := os.Hostname()
+	body, err := json.Marshal(&idleWarning{
+		Event:    "idle_warning",
+		Hostname: hostname,
+		Address:  addr,
+		Seconds:  lead.Seconds(),
+	})
+	if err != nil {
+		log.Print("Idle warning: ", err)
+		return
+	}
+	resp, err := idleWarnClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Print("Idle warning: ", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Print("Idle warning: webhook returned ", resp.Status)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/tools/godoc"
+)
+
+// implFact is one implements relation of a type.
+type implFact struct {
+	// "implements" if the queried type implements Type,
+	// "implemented_by" if Type implements the queried interface
+	Relation string `json:"relation"`
+	Kind     string `json:"kind,omitempty"` // kind of Type for implemented_by, e.g. "struct" or "pointer"
+	Type     string `json:"type"`
+	Href     string `json:"href"`
+}
+
+var htmlTagRx = regexp.MustCompile(`<[^>]*>`)
+
+// implementsHandler serves /api/implements?type=io.Reader, the implements
+// relations of a type found by type analysis, as JSON.
+type implementsHandler struct {
+	corpus *godoc.Corpus
+}
+
+func (h implementsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if *analysisFlag == "" || atomic.LoadInt32(&analysisPending) != 0 {
+		apiError(w, http.StatusServiceUnavailable, "type analysis results are not available")
+		return
+	}
+	typ := r.FormValue("type")
+	i := strings.LastIndex(typ, ".")
+	if i <= 0 || i == len(typ)-1 {
+		apiError(w, http.StatusBadRequest, "type must be importpath.Name")
+		return
+	}
+	pkg, name := typ[:i], typ[i+1:]
+
+	for _, ti := range h.corpus.Analysis.PackageInfo(pkg).Types {
+		if ti.Name != name {
+			continue
+		}
+		facts := []implFact{}
+		for _, g := range ti.ImplGroups {
+			for _, f := range g.Facts {
+				fact := implFact{
+					Relation: "implements",
+					Type:     html.UnescapeString(htmlTagRx.ReplaceAllString(f.Other.Text, "")),
+					Href:     f.Other.Href,
+				}
+				if f.ByKind != "" {
+					fact.Relation = "implemented_by"
+					fact.Kind = f.ByKind
+				}
+				facts = append(facts, fact)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(facts)
+		return
+	}
+	apiError(w, http.StatusNotFound, "type not found")
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc"
+)
+
+// maxImportDepth bounds depth of /api/imports?transitive=true.
+const maxImportDepth = 10
+
+// importGraph is the response of /api/imports.
+type importGraph struct {
+	Package    string   `json:"package"`
+	Imports    []string `json:"imports"`
+	ImportedBy []string `json:"imported_by"`
+}
+
+// importsHandler serves /api/imports?pkg=net/http, the packages the package
+// imports and the packages of the corpus importing it, as JSON. With
+// transitive=true, indirect ones are included too, up to depth levels
+// (default and maximum maxImportDepth).
+type importsHandler struct {
+	pres *godoc.Presentation
+
+	mu      sync.Mutex
+	fsTime  time.Time
+	imports map[string][]string // by import path, for all packages
+	revs    map[string][]string // reverse of imports
+}
+
+func (h *importsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pkg := strings.Trim(r.FormValue("pkg"), "/")
+	if pkg == "" || path.Clean(pkg) != pkg || strings.HasPrefix(pkg, "..") {
+		apiError(w, http.StatusBadRequest, "pkg is required")
+		return
+	}
+	depth := 1
+	if r.FormValue("transitive") == "true" {
+		depth = maxImportDepth
+		if s := r.FormValue("depth"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxImportDepth {
+				apiError(w, http.StatusBadRequest, "depth must be between 1 and "+strconv.Itoa(maxImportDepth))
+				return
+			}
+			depth = n
+		}
+	}
+
+	imports, revs := h.graph()
+	if _, ok := imports[pkg]; !ok {
+		apiError(w, http.StatusNotFound, "package not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(importGraph{
+		Package:    pkg,
+		Imports:    reachable(imports, pkg, depth),
+		ImportedBy: reachable(revs, pkg, depth),
+	})
+}
+
+// graph returns the import graph of all packages, rebuilding it
+// if the file system tree has changed since.
+func (h *importsHandler) graph() (imports, revs map[string][]string) {
+	fsTime := h.pres.Corpus.FSModifiedTime()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.imports != nil && fsTime.Equal(h.fsTime) {
+		return h.imports, h.revs
+	}
+	imports = make(map[string][]string)
+	revs = make(map[string][]string)
+	for _, d := range listPackages(h.pres) {
+		list, err := h.packageImports(d.Path)
+		if err != nil {
+			log.Printf("Import graph: %s: %v", d.Path, err)
+			continue
+		}
+		imports[d.Path] = list
+		for _, imp := range list {
+			revs[imp] = append(revs[imp], d.Path)
+		}
+	}
+	h.fsTime, h.imports, h.revs = fsTime, imports, revs
+	return imports, revs
+}
+
+// packageImports returns the imports of the package at importPath.
+func (h *importsHandler) packageImports(importPath string) (list []string, err error) {
+	defer func() {
+		// godoc can't handle some packages, see pinnedPages.render
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	info := h.pres.GetPkgPageInfo("/src/"+importPath, importPath, godoc.NoFiltering)
+	if info.Err != nil {
+		return nil, info.Err
+	}
+	if info.PDoc == nil {
+		return nil, errors.New("no package")
+	}
+	return info.PDoc.Imports, nil
+}
+
+// reachable returns the sorted packages reachable from pkg in graph
+// in at most depth steps, excluding pkg itself.
+func reachable(graph map[string][]string, pkg string, depth int) []string {
+	seen := map[string]bool{pkg: true}
+	level := []string{pkg}
+	list := []string{}
+	for ; depth > 0 && len(level) > 0; depth-- {
+		var next []string
+		for _, p := range level {
+			for _, q := range graph[p] {
+				if !seen[q] {
+					seen[q] = true
+					list = append(list, q)
+					next = append(next, q)
+				}
+			}
+		}
+		level = next
+	}
+	sort.Strings(list)
+	return list
+}
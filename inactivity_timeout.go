@@ -0,0 +1,152 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// inactivityTimeoutFlag is a duration, optionally followed or replaced by
+// per socket name overrides, e.g. '5m' or 'public=5m,admin=0'.
+// Daily time windows override the duration while they are open, e.g.
+// '09:00-18:00=30m,else=2m'; the first open one applies.
+type inactivityTimeoutFlag struct {
+	d        time.Duration
+	byName   map[string]time.Duration
+	schedule []scheduledTimeout
+}
+
+type scheduledTimeout struct {
+	w timeWindow
+	d time.Duration
+}
+
+func (f *inactivityTimeoutFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var s []string
+	for _, st := range f.schedule {
+		s = append(s, fmt.Sprintf("%s=%v", st.w, st.d))
+	}
+	if len(s) > 0 {
+		s = append(s, "else="+f.d.String())
+	} else {
+		s = append(s, f.d.String())
+	}
+	var names []string
+	for name := range f.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s = append(s, name+"="+f.byName[name].String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *inactivityTimeoutFlag) Set(value string) error {
+	f.byName, f.schedule = nil, nil
+	for _, part := range strings.Split(value, ",") {
+		name, dur := "", strings.TrimSpace(part)
+		if i := strings.Index(dur, "="); i >= 0 {
+			name, dur = strings.TrimSpace(dur[:i]), strings.TrimSpace(dur[i+1:])
+		}
+		d, err := time.ParseDuration(dur)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%q is not a duration", dur)
+		}
+		if name == "" || name == "else" {
+			f.d = d
+			continue
+		}
+		if strings.Contains(name, ":") {
+			// socket names can't contain colons
+			w, err := parseTimeWindow(name)
+			if err != nil {
+				return err
+			}
+			f.schedule = append(f.schedule, scheduledTimeout{w, d})
+			continue
+		}
+		if f.byName == nil {
+			f.byName = make(map[string]time.Duration)
+		}
+		f.byName[name] = d
+	}
+	return nil
+}
+
+// at returns the timeout at t of sockets without one of their own.
+func (f *inactivityTimeoutFlag) at(t time.Time) time.Duration {
+	for _, st := range f.schedule {
+		if st.w.contains(t) {
+			return st.d
+		}
+	}
+	return f.d
+}
+
+// forName returns all timeouts the socket named name may have,
+// and whether it has one of its own.
+func (f *inactivityTimeoutFlag) forName(name string) ([]time.Duration, bool) {
+	if d, ok := f.byName[name]; ok {
+		return []time.Duration{d}, true
+	}
+	ds := []time.Duration{f.d}
+	for _, st := range f.schedule {
+		ds = append(ds, st.d)
+	}
+	return ds, false
+}
+
+// timeoutListener tags accepted connections with the inactivity timeout
+// of the socket, see connTimeout.
+type timeoutListener struct {
+	net.Listener
+	timeout time.Duration
+}
+
+func (l timeoutListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return timeoutConn{c, l.timeout}, nil
+}
+
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+// connTimeout returns the inactivity timeout of the socket c was accepted on,
+// or false if it's not known.
+func connTimeout(c net.Conn) (time.Duration, bool) {
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+	if tc, ok := c.(timeoutConn); ok {
+		return tc.timeout, true
+	}
+	return 0, false
+}
+
+type connTimeoutKey struct{}
+
+// connTimeoutContext is http.Server.ConnContext making connTimeout
+// available to lastActivityHTTPHandler.
+func connTimeoutContext(ctx context.Context, c net.Conn) context.Context {
+	if d, ok := connTimeout(c); ok {
+		ctx = context.WithValue(ctx, connTimeoutKey{}, d)
+	}
+	return ctx
+}
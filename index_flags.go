@@ -0,0 +1,73 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+)
+
+// indexOnlyFlags have no effect unless the search index is built or loaded.
+var indexOnlyFlags = []string{"index_files", "index_interval", "index_window", "index_throttle", "index_max_load_bytes", "maxresults"}
+
+// indexFlags are the values of -index and related flags
+// checked by checkIndexFlags.
+type indexFlags struct {
+	index      bool
+	files      string
+	maxResults int
+	exportFile string
+	set        map[string]bool // names of flags given explicitly
+}
+
+// commandLineIndexFlags returns the index flags of the command line
+// (including those set from GODOC_ variables).
+func commandLineIndexFlags() indexFlags {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return indexFlags{
+		index:      *indexEnabled,
+		files:      *indexFiles,
+		maxResults: *maxResults,
+		exportFile: *exportIndexFile,
+		set:        set,
+	}
+}
+
+// checkIndexFlags reports contradictory combinations of -index and
+// related flags, which would otherwise leave search silently not working
+// the way it was configured.
+func checkIndexFlags(f indexFlags) error {
+	var problems []string
+	if !f.index && f.exportFile == "" {
+		for _, name := range indexOnlyFlags {
+			if f.set[name] {
+				problems = append(problems, "-"+name+" has no effect without -index")
+			}
+		}
+	}
+	if f.files != "" {
+		// RunIndexer loads the files once, without reindexing
+		if f.set["index_interval"] {
+			problems = append(problems, "the index is read from -index_files once and never rebuilt, so -index_interval has no effect; drop one of them")
+		}
+		if f.set["index_window"] {
+			problems = append(problems, "the index is read from -index_files once and never rebuilt, so -index_window has no effect; drop one of them")
+		}
+	} else if f.set["index_max_load_bytes"] {
+		problems = append(problems, "-index_max_load_bytes limits -index_files, which is empty")
+	}
+	if f.maxResults < 0 {
+		problems = append(problems, "-maxresults must not be negative; 0 disables full-text search")
+	}
+	if f.maxResults == 0 && f.exportFile != "" && f.files == "" {
+		problems = append(problems, "-maxresults=0 disables full-text indexing, so -export_index would have no text records")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("search index flags: " + strings.Join(problems, "; "))
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckIndexFlags(t *testing.T) {
+	set := func(names ...string) map[string]bool {
+		m := make(map[string]bool)
+		for _, n := range names {
+			m[n] = true
+		}
+		return m
+	}
+	tests := []struct {
+		name  string
+		flags indexFlags
+		want  string // substring of the error; empty if valid
+	}{
+		{"defaults", indexFlags{maxResults: 10000, set: set()}, ""},
+		{"index", indexFlags{index: true, maxResults: 10000, set: set("index")}, ""},
+		{"index files", indexFlags{index: true, files: "idx*", maxResults: 10000, set: set("index", "index_files")}, ""},
+		{"full-text off", indexFlags{index: true, maxResults: 0, set: set("index", "maxresults")}, ""},
+		{"export", indexFlags{maxResults: 10000, exportFile: "out.json", set: set("export_index", "index_interval")}, ""},
+		{"export from files", indexFlags{files: "idx*", maxResults: 0, exportFile: "out.json", set: set("export_index", "index_files", "maxresults")}, ""},
+
+		{"index files without index", indexFlags{files: "idx*", maxResults: 10000, set: set("index_files")}, "-index_files has no effect without -index"},
+		{"interval without index", indexFlags{maxResults: 10000, set: set("index_interval")}, "-index_interval has no effect without -index"},
+		{"maxresults without index", indexFlags{maxResults: 0, set: set("maxresults")}, "-maxresults has no effect without -index"},
+		{"interval with index files", indexFlags{index: true, files: "idx*", maxResults: 10000, set: set("index", "index_files", "index_interval")}, "-index_interval has no effect"},
+		{"window with index files", indexFlags{index: true, files: "idx*", maxResults: 10000, set: set("index", "index_files", "index_window")}, "-index_window has no effect"},
+		{"max load bytes without index files", indexFlags{index: true, maxResults: 10000, set: set("index", "index_max_load_bytes")}, "-index_max_load_bytes limits -index_files"},
+		{"negative maxresults", indexFlags{index: true, maxResults: -1, set: set("index", "maxresults")}, "-maxresults must not be negative"},
+		{"export without full-text", indexFlags{maxResults: 0, exportFile: "out.json", set: set("export_index", "maxresults")}, "-export_index would have no text records"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkIndexFlags(tt.flags)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("no error, want one containing %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("error %q doesn't contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckIndexFlagsAllProblems(t *testing.T) {
+	err := checkIndexFlags(indexFlags{files: "idx*", maxResults: -1, set: map[string]bool{"index_files": true, "index_window": true, "maxresults": true}})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{"-index_files has no effect", "-index_window has no effect without -index", "-index_window has no effect; drop one", "must not be negative"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+}
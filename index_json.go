@@ -0,0 +1,81 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	rdebug "runtime/debug" // debug is the -debug flag
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/godoc"
+)
+
+// indexInfo is the document served at /index.json.
+type indexInfo struct {
+	GoVersion       string            `json:"go_version"`
+	Goroot          string            `json:"goroot"`
+	Search          bool              `json:"search"`
+	Analysis        bool              `json:"analysis"`
+	AnalysisPending bool              `json:"analysis_pending"`
+	Packages        int               `json:"packages"`
+	Build           *rdebug.BuildInfo `json:"build,omitempty"`
+}
+
+// indexJSONHandler serves /index.json, a capability descriptor for
+// documentation portals. The encoded document is rebuilt only when
+// the file system tree, the search index or the analysis state changes.
+type indexJSONHandler struct {
+	pres *godoc.Presentation
+
+	mu      sync.Mutex
+	fsTime  time.Time
+	idxTime time.Time
+	pending bool
+	data    []byte
+}
+
+func (h *indexJSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := h.get()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (h *indexJSONHandler) get() ([]byte, error) {
+	c := h.pres.Corpus
+	fsTime := c.FSModifiedTime()
+	_, idxTime := c.CurrentIndex()
+	pending := atomic.LoadInt32(&analysisPending) != 0
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.data != nil && fsTime.Equal(h.fsTime) && idxTime.Equal(h.idxTime) && pending == h.pending {
+		return h.data, nil
+	}
+	info := indexInfo{
+		GoVersion:       runtime.Version(),
+		Goroot:          *goroot,
+		Search:          c.IndexEnabled,
+		Analysis:        *analysisFlag != "",
+		AnalysisPending: pending,
+		Packages:        len(listPackages(h.pres)),
+	}
+	if bi, ok := rdebug.ReadBuildInfo(); ok {
+		info.Build = bi
+	}
+	data, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	h.fsTime, h.idxTime, h.pending, h.data = fsTime, idxTime, pending, data
+	return data, nil
+}
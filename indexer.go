@@ -0,0 +1,161 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc"
+)
+
+// indexer replaces godoc.Corpus.RunIndexer loop, so that index passes
+// can also be triggered on demand.
+type indexer struct {
+	corpus   *godoc.Corpus
+	interval time.Duration // same as godoc.Corpus.IndexInterval
+	// if set, passes after the first one only start within it
+	window *timeWindow
+
+	mu      sync.Mutex
+	running chan struct{} // closed when current pass completes; nil if idle
+	stopped chan struct{} // closed by stop
+}
+
+func newIndexer(c *godoc.Corpus, interval time.Duration) *indexer {
+	// With negative interval, RunIndexer refreshes the directory tree,
+	// updates the index once and returns, which is exactly one pass.
+	c.IndexInterval = -1
+	return &indexer{corpus: c, interval: interval, stopped: make(chan struct{})}
+}
+
+// run runs index passes forever, unless the interval is negative.
+func (x *indexer) run() {
+	for {
+		x.pass()
+		if x.interval < 0 || x.corpus.IndexFiles != "" {
+			return
+		}
+		delay := 5 * time.Minute // by default, reindex every 5 minutes
+		if x.interval > 0 {
+			delay = x.interval
+		}
+		if !x.sleep(delay) {
+			return
+		}
+		if x.window != nil {
+			if d := x.window.untilOpen(time.Now()); d > 0 {
+				log.Printf("Next index pass at the start of -index_window, in %v", d.Round(time.Minute))
+				if !x.sleep(d) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// sleep waits for d, returning false if stop is called in the meantime.
+func (x *indexer) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-x.stopped:
+		return false
+	}
+}
+
+// stop makes run return instead of starting another pass.
+// A pass in progress can't be interrupted, and just dies with the process.
+func (x *indexer) stop() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	select {
+	case <-x.stopped:
+	default:
+		close(x.stopped)
+	}
+}
+
+// pass runs a single index pass, or, if one is already in progress,
+// waits for it to complete.
+func (x *indexer) pass() {
+	x.mu.Lock()
+	if done := x.running; done != nil {
+		x.mu.Unlock()
+		<-done
+		return
+	}
+	done := make(chan struct{})
+	x.running = done
+	x.mu.Unlock()
+
+	x.corpus.RunIndexer()
+
+	x.mu.Lock()
+	x.running = nil
+	x.mu.Unlock()
+	close(done)
+}
+
+// ServeHTTP runs an index pass and responds when it completes.
+func (x *indexer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	start := time.Now()
+	x.pass()
+	fmt.Fprintf(w, "index rebuilt in %s\n", time.Since(start))
+}
+
+// globSize returns total size of files matching the glob pattern.
+func globSize(pattern string) (int64, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err != nil {
+			return 0, err
+		}
+		size += fi.Size()
+	}
+	return size, nil
+}
+
+// indexSizeError reports index files over -index_max_load_bytes.
+type indexSizeError struct {
+	pattern string
+	size    int64
+}
+
+func (e *indexSizeError) Error() string {
+	return fmt.Sprintf("index files %s are %d bytes, over -index_max_load_bytes", e.pattern, e.size)
+}
+
+// checkIndexLoadSize checks that the index files matching pattern are
+// at most max bytes in total, returning an *indexSizeError if they
+// aren't; max <= 0 means no limit.
+func checkIndexLoadSize(pattern string, max int64) error {
+	if max <= 0 {
+		return nil
+	}
+	size, err := globSize(pattern)
+	if err != nil {
+		return err
+	}
+	if size > max {
+		return &indexSizeError{pattern, size}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckIndexLoadSize(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "index.0", strings.Repeat("x", 100))
+	writeTestFile(t, dir, "index.1", strings.Repeat("x", 50))
+	pattern := filepath.Join(dir, "index.*")
+
+	tests := []struct {
+		pattern  string
+		max      int64
+		tooLarge bool
+	}{
+		{pattern, 0, false}, // no limit
+		{pattern, 150, false},
+		{pattern, 149, true},
+		{filepath.Join(dir, "index.0"), 100, false},
+		{filepath.Join(dir, "none.*"), 1, false}, // no files, nothing to load
+	}
+	for _, tt := range tests {
+		err := checkIndexLoadSize(tt.pattern, tt.max)
+		if _, ok := err.(*indexSizeError); ok != tt.tooLarge || (err != nil && !ok) {
+			t.Errorf("checkIndexLoadSize(%s, %d) = %v, want too large: %v", tt.pattern, tt.max, err, tt.tooLarge)
+		}
+	}
+
+	if err := checkIndexLoadSize("[", 1); err == nil {
+		t.Error("checkIndexLoadSize with a malformed pattern succeeded")
+	} else if _, ok := err.(*indexSizeError); ok {
+		t.Errorf("checkIndexLoadSize with a malformed pattern = %v, want a pattern error", err)
+	}
+}
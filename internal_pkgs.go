@@ -0,0 +1,59 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/godoc"
+)
+
+// showInternalMode is godoc.Presentation.AdjustPageInfoMode for -show_internal.
+// godoc lists internal (and vendor) directories only in godoc.NoFiltering mode,
+// which also shows unexported declarations, so it's only set for directories
+// without a package of their own, like /pkg/ itself. Package pages of
+// internal packages are rendered as usual either way.
+func showInternalMode(r *http.Request, mode godoc.PageInfoMode) godoc.PageInfoMode {
+	if !strings.HasPrefix(r.URL.Path, "/pkg/") {
+		return mode
+	}
+	dir := path.Join("/src", pkgImportPath(r.URL.Path))
+	if !hasGoFiles(dir) {
+		mode |= godoc.NoFiltering
+	}
+	return mode
+}
+
+// hasGoFiles tells whether dir has any .go files.
+func hasGoFiles(dir string) bool {
+	list, err := fs.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, fi := range list {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".go") {
+			return true
+		}
+	}
+	return false
+}
+
+// showInternalFuncs keeps the NoFiltering mode set by showInternalMode
+// from leaking into links (as ?m=all) to the listed packages.
+func showInternalFuncs(p *godoc.Presentation) template.FuncMap {
+	v := p.FuncMap()["modeQueryString"]
+	modeQueryString, ok := v.(func(godoc.PageInfoMode) string)
+	if !ok {
+		incompatibleFunc("show_internal", "modeQueryString", v)
+	}
+	return template.FuncMap{
+		"modeQueryString": func(mode godoc.PageInfoMode) string {
+			return modeQueryString(mode &^ godoc.NoFiltering)
+		},
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/godoc"
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+var internalTestFiles = map[string]string{
+	"src/p/p.go":            "// Package p is public.\npackage p\n",
+	"src/p/internal/q/q.go": "// Package q is internal to p.\npackage q\n",
+	"src/internal/r/r.go":   "// Package r is internal.\npackage r\n",
+}
+
+// servePkg serves urlPath with p, which must reply 200.
+func servePkg(t *testing.T, p *godoc.Presentation, urlPath string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", urlPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status = %d, want 200", urlPath, w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestShowInternal(t *testing.T) {
+	tests := []struct {
+		show bool
+		want bool // whether internal packages are listed in /pkg/
+	}{
+		{false, false},
+		{true, true},
+	}
+	for _, tt := range tests {
+		old := *showInternal
+		*showInternal = tt.show
+		p := newTestPresentation(t, mapfs.New(internalTestFiles))
+		*showInternal = old
+		if tt.show {
+			p.AdjustPageInfoMode = showInternalMode
+		}
+
+		body := servePkg(t, p, "/pkg/")
+		for _, dir := range []string{"internal/", "p/internal/"} {
+			if got := strings.Contains(body, `href="`+dir); got != tt.want {
+				t.Errorf("-show_internal=%v: /pkg/ lists %s: %v, want %v", tt.show, dir, got, tt.want)
+			}
+		}
+		if !strings.Contains(body, `href="p/"`) {
+			t.Errorf("-show_internal=%v: /pkg/ doesn't list p", tt.show)
+		}
+		if strings.Contains(body, "m=all") {
+			t.Errorf("-show_internal=%v: /pkg/ links have m=all", tt.show)
+		}
+
+		// package pages of internal packages are rendered either way
+		if body := servePkg(t, p, "/pkg/internal/r/"); !strings.Contains(body, "Package r is internal.") {
+			t.Errorf("-show_internal=%v: /pkg/internal/r/ doesn't have the package doc", tt.show)
+		}
+	}
+}
+
+func TestShowInternalMode(t *testing.T) {
+	newTestPresentation(t, mapfs.New(internalTestFiles))
+	tests := []struct {
+		path string
+		want godoc.PageInfoMode
+	}{
+		{"/pkg/", godoc.NoFiltering},
+		{"/pkg/internal/", godoc.NoFiltering},
+		{"/pkg/p/", 0}, // has a package, which would show unexported declarations
+		{"/pkg/internal/r/", 0},
+		{"/src/internal/", 0}, // not a package page
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if got := showInternalMode(r, 0); got != tt.want {
+			t.Errorf("showInternalMode(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"io/ioutil"
+	"os"
+	pathpkg "path"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// ioFS adapts an io/fs file system (e.g. embed.FS) to vfs.FileSystem,
+// so it can be bound into the name space like zipfs or vfs.OS.
+type ioFS struct {
+	fsys iofs.FS
+	name string
+}
+
+func newIOFS(fsys iofs.FS, name string) vfs.FileSystem {
+	return &ioFS{fsys: fsys, name: name}
+}
+
+// fsPath converts an absolute slash-separated vfs path into an io/fs one.
+func (f *ioFS) fsPath(p string) string {
+	p = strings.TrimPrefix(pathpkg.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func (f *ioFS) Open(p string) (vfs.ReadSeekCloser, error) {
+	file, err := f.fsys.Open(f.fsPath(p))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("Open: %s is a directory", p)
+	}
+	if rsc, ok := file.(vfs.ReadSeekCloser); ok {
+		return rsc, nil
+	}
+	// not seekable; read it into memory
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+// io/fs has no notion of symbolic links, so Lstat is the same as Stat.
+func (f *ioFS) Lstat(p string) (os.FileInfo, error) {
+	return iofs.Stat(f.fsys, f.fsPath(p))
+}
+
+func (f *ioFS) Stat(p string) (os.FileInfo, error) {
+	return iofs.Stat(f.fsys, f.fsPath(p))
+}
+
+func (f *ioFS) ReadDir(p string) ([]os.FileInfo, error) {
+	entries, err := iofs.ReadDir(f.fsys, f.fsPath(p))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, fi)
+	}
+	return list, nil
+}
+
+func (f *ioFS) String() string {
+	return f.name
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,34 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"time"
+)
+
+// keepAliveListener applies -tcp_keepalive to TCP connections accepted
+// from socket-activated listeners, which net.ListenConfig can't configure.
+// Other connections (e.g. on Unix sockets) are left alone.
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration // negative disables keep-alives
+}
+
+func (l keepAliveListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tc, ok := c.(*net.TCPConn); ok {
+		if l.period < 0 {
+			tc.SetKeepAlive(false)
+		} else {
+			tc.SetKeepAlive(true)
+			tc.SetKeepAlivePeriod(l.period)
+		}
+	}
+	return c, nil
+}
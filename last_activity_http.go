@@ -5,7 +5,9 @@
 package main
 
 import (
+	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,25 +15,181 @@ import (
 type lastActivityHTTPHandler struct {
 	h http.Handler
 
-	duration   time.Duration
-	timer      *time.Timer
-	timerMutex sync.Mutex
+	// requests with these methods don't count as activity
+	ignoreMethods map[string]bool
+	// requests to these paths (e.g. health probes) don't count as activity
+	ignorePaths map[string]bool
+	// requests to paths matching these don't count if answered 404
+	quiet404 pathPatterns
+
+	// timeout of requests on connections without one of their own,
+	// see connTimeout; schedule, if set, overrides it by time of day
+	duration     time.Duration
+	schedule     func(time.Time) time.Duration
+	timer        *time.Timer
+	timerMutex   sync.Mutex
+	lastActivity time.Time
+	// when the timer fires, unless stopped by connState
+	deadline time.Time
+
+	// fires warnLead before timer, see warnBefore
+	warnTimer *time.Timer
+	warnLead  time.Duration
+
+	// rate of requests counting as activity
+	rate requestRate
+
+	// connections with requests in progress, see connState;
+	// nil unless enabled with trackConns
+	active map[net.Conn]bool
 }
 
 func (h *lastActivityHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.timerMutex.Lock()
-	h.timer.Reset(h.duration)
-	h.timerMutex.Unlock()
+	d, ok := r.Context().Value(connTimeoutKey{}).(time.Duration)
+	if !ok {
+		d = h.defaultDuration()
+	}
+	// requests on sockets with zero timeout don't count either
+	counts := d > 0 && !h.ignoreMethods[r.Method] && !h.ignorePaths[r.URL.Path]
+	if counts && h.quiet404.match(r.URL.Path) {
+		// bots probing for icons and such mustn't keep the server alive,
+		// so count only past the response
+		rec := &statusRecorder{ResponseWriter: w}
+		h.h.ServeHTTP(rec, r)
+		if rec.status() != http.StatusNotFound {
+			h.rate.add(time.Now())
+			h.activity(d)
+		}
+		return
+	}
+	if counts {
+		h.rate.add(time.Now())
+		h.activity(d)
+	}
 	h.h.ServeHTTP(w, r)
+	if counts && h.active != nil {
+		// count from the end of long requests, like downloads
+		h.activity(d)
+	}
+}
+
+// defaultDuration returns the current timeout of requests on connections
+// without one of their own.
+func (h *lastActivityHTTPHandler) defaultDuration() time.Duration {
+	if h.schedule != nil {
+		return h.schedule(time.Now())
+	}
+	return h.duration
+}
+
+// activity postpones the timer to at least d from now.
+func (h *lastActivityHTTPHandler) activity(d time.Duration) {
+	h.timerMutex.Lock()
+	defer h.timerMutex.Unlock()
+	h.lastActivity = time.Now()
+	if deadline := h.lastActivity.Add(d); deadline.After(h.deadline) {
+		h.deadline = deadline
+	}
+	if len(h.active) == 0 {
+		h.resetTimers(time.Until(h.deadline))
+	}
+}
+
+// resetTimers makes the timer fire in d. timerMutex must be held.
+func (h *lastActivityHTTPHandler) resetTimers(d time.Duration) {
+	h.timer.Reset(d)
+	if h.warnTimer != nil {
+		h.warnTimer.Reset(d - h.warnLead)
+	}
+}
+
+// trackConns makes the timer wait while any connection has a request
+// in progress, even with no new requests coming (e.g. a long download).
+// connState must be set as http.Server.ConnState.
+func (h *lastActivityHTTPHandler) trackConns() {
+	h.timerMutex.Lock()
+	defer h.timerMutex.Unlock()
+	h.active = make(map[net.Conn]bool)
+}
+
+// connState stops the timer while some connections are active,
+// and resumes it, counting from the last activity, once none are.
+// Connections on sockets with zero timeout are ignored.
+func (h *lastActivityHTTPHandler) connState(c net.Conn, state http.ConnState) {
+	d, ok := connTimeout(c)
+	if !ok {
+		d = h.defaultDuration()
+	}
+	if d == 0 {
+		return
+	}
+	h.timerMutex.Lock()
+	defer h.timerMutex.Unlock()
+	isActive := state == http.StateActive
+	if isActive == h.active[c] {
+		return
+	}
+	if isActive {
+		h.active[c] = true
+		if len(h.active) == 1 {
+			h.timer.Stop()
+			if h.warnTimer != nil {
+				h.warnTimer.Stop()
+			}
+		}
+		return
+	}
+	delete(h.active, c)
+	if len(h.active) == 0 {
+		// requests that don't count as activity don't postpone the timer
+		h.resetTimers(time.Until(h.deadline))
+	}
 }
 
 func newLastActivityHTTPHandler(h http.Handler, d time.Duration) *lastActivityHTTPHandler {
 	if h == nil {
 		h = http.DefaultServeMux
 	}
+	now := time.Now()
 	return &lastActivityHTTPHandler{
-		h:        h,
-		duration: d,
-		timer:    time.NewTimer(d),
+		h:            h,
+		duration:     d,
+		timer:        time.NewTimer(d),
+		lastActivity: now,
+		deadline:     now.Add(d),
+	}
+}
+
+// last returns the time of the last activity (or of the start).
+func (h *lastActivityHTTPHandler) last() time.Time {
+	h.timerMutex.Lock()
+	defer h.timerMutex.Unlock()
+	return h.lastActivity
+}
+
+// remaining returns how long until the timer fires, if no activity comes.
+func (h *lastActivityHTTPHandler) remaining() time.Duration {
+	h.timerMutex.Lock()
+	defer h.timerMutex.Unlock()
+	return time.Until(h.deadline)
+}
+
+// warnBefore arranges for f to be called in its own goroutine lead before
+// the inactivity timer fires. Any activity in between postpones it, along with the timer.
+func (h *lastActivityHTTPHandler) warnBefore(lead time.Duration, f func()) {
+	h.timerMutex.Lock()
+	defer h.timerMutex.Unlock()
+	h.warnLead = lead
+	h.warnTimer = time.AfterFunc(time.Until(h.deadline)-lead, f)
+}
+
+// parseMethodSet parses comma-separated list of HTTP methods.
+func parseMethodSet(s string) map[string]bool {
+	methods := make(map[string]bool)
+	for _, m := range strings.Split(s, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			methods[strings.ToUpper(m)] = true
+		}
 	}
+	return methods
 }
@@ -0,0 +1,23 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+)
+
+// listenTCP listens on addr, honoring -tcp_keepalive. -so_reuseport and -listen_backlog
+// aren't supported on this platform and are ignored.
+func listenTCP(addr string) (net.Listener, error) {
+	if *soReuseport || *listenBacklog > 0 {
+		log.Print("-so_reuseport and -listen_backlog aren't supported on this platform, ignoring")
+	}
+	lc := net.ListenConfig{KeepAlive: *tcpKeepAlive}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
@@ -7,109 +7,299 @@
 package main
 
 import (
-	"archive/zip"
 	"context"
 	_ "expvar" // to serve /debug/vars
 	"flag"
 	"go/build"
 	"log"
+	"math/rand"
 	"net"
 	"net/http"
 	_ "net/http/pprof" // to serve /debug/pprof/*
+	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/tools/godoc"
-	"golang.org/x/tools/godoc/analysis"
 	"golang.org/x/tools/godoc/static"
 	"golang.org/x/tools/godoc/vfs"
-	"golang.org/x/tools/godoc/vfs/gatefs"
 	"golang.org/x/tools/godoc/vfs/mapfs"
 	"golang.org/x/tools/godoc/vfs/zipfs"
-
-	"github.com/coreos/go-systemd/activation"
 )
 
 const defaultAddr = ":6060" // default webserver address
 
+// fsGateSize limits concurrent operations on the OS file system
+const fsGateSize = 20
+
 var (
-	zipfile = flag.String("zip", "", "zip file providing the file system to serve; disabled if empty")
+	zipfile  = flag.String("zip", "", "zip file providing the file system to serve; disabled if empty")
+	zipRoot  = flag.String("zip_root", "", "directory inside the -zip file to serve as Go root; detected if empty")
+	zipCache = flag.Bool("zip_cache", true, "keep file and directory metadata of -zip, -zip_glob and -diff_zip files in memory")
+	zipGlob  = flag.String("zip_glob", "", "glob pattern of zip files (e.g. '/srv/docs/*.zip') whose package trees are added after the others under /src, in sorted order; matched at startup")
+	fsysFlag = flag.String("fsys", "os", "how directories (-goroot, GOPATH, -single_dir) are read: 'os', or 'iofs' through os.DirFS, for read-only images such as mounted squashfs")
+	embedded = flag.Bool("embedded", false, "serve the file system embedded into the binary (requires building with -tags embedded)")
+
+	singleDir  = flag.String("single_dir", "", "directory with a package to serve at the root URL, e.g. the module being developed")
+	vendorRoot = flag.String("vendor_root", "", "directory with import path trees (like a vendor directory) shadowing packages from GOROOT and GOPATH")
+
+	diffZip = flag.String("diff_zip", "", "zip file with an older release; if not empty, API changes against it are reported at /diff")
+	gitRepo = flag.String("git_repo", "", "git repository whose commits are served at /ref/<commit or branch>/pkg/..., each checked out into a temporary worktree")
+
+	modCache = flag.Bool("mod_cache", false, "serve /pkg/<module>@<version>/... and /src/<module>@<version>/... from the module cache (GOMODCACHE), read-only, through the same handlers as other packages")
 
-	analysisFlag = flag.String("analysis", "", `comma-separated list of analyses to perform (supported: type, pointer). See http://golang.org/lib/godoc/analysis/help.html`)
+	analysisFlag          = flag.String("analysis", "", `comma-separated list of analyses to perform ('list' shows supported ones). See http://golang.org/lib/godoc/analysis/help.html`)
+	analysisLazy          = flag.Bool("analysis_lazy", false, "defer whole-program -analysis until the first package, source page or /api/implements request, instead of running it at startup")
+	analysisCacheDir      = flag.String("analysis_cache_dir", "", "directory keeping package and source pages rendered with -analysis results across restarts")
+	analysisCacheMaxBytes = flag.Int64("analysis_cache_max_bytes", 256<<20, "size limit of -analysis_cache_dir; least recently used pages are removed beyond it")
 
 	httpAddr = flag.String("http", defaultAddr, "HTTP service address (e.g., '"+defaultAddr+"')")
 
-	inactivityTimeout = flag.Duration("inactivity_timeout", 5*time.Minute, "Inactivity timeout for socket activation")
+	tlsCert     = flag.String("tls_cert", "", "TLS certificate file; if set with -tls_key, HTTPS is served instead of HTTP, on socket-activated listeners too")
+	tlsKey      = flag.String("tls_key", "", "TLS private key file")
+	tlsClientCA = flag.String("tls_client_ca", "", "file with PEM CA certificates; if not empty, clients must present a certificate signed by one of them")
+
+	soReuseport   = flag.Bool("so_reuseport", false, "set SO_REUSEPORT on the -http listener, so that several instances can share the port; not applied to socket-activated listeners")
+	listenBacklog = flag.Int("listen_backlog", 0, "accept backlog of the -http listener; 0 for the system default")
+	tcpKeepAlive  = flag.Duration("tcp_keepalive", 0, "TCP keep-alive probe period of accepted connections, detecting dead peers; 0 for Go's default (15s), negative to disable. Unrelated to -inactivity_timeout, which shuts the server down")
+
+	inactivityTimeout = &inactivityTimeoutFlag{d: 5 * time.Minute}
+
+	onIdleExec    = flag.String("on_idle_exec", "", "shell command to run before shutting down after inactivity timeout; killed if it takes too long")
+	fdStore       = flag.Bool("fdstore", false, "hand the listening socket over to systemd's fd store, so that the next start of the service reuses it")
+	logActivation = flag.Bool("log_activation", false, "log the socket activation environment (LISTEN_FDS, LISTEN_PID, LISTEN_FDNAMES) and the listeners passed")
+
+	inactivityTrackConns    = flag.Bool("inactivity_track_conns", false, "don't time out while any connection has a request in progress, e.g. a long download; the timeout counts from its end")
+	inactivityIgnoreMethods = flag.String("inactivity_ignore_methods", "HEAD,OPTIONS", "comma-separated list of HTTP methods that don't reset the inactivity timer")
+
+	idleWarnWebhook = flag.String("idle_warn_webhook", "", "URL to POST a JSON notice to -idle_warn_lead before shutting down after inactivity")
+	idleWarnLead    = flag.Duration("idle_warn_lead", 30*time.Second, "how long before inactivity shutdown -idle_warn_webhook is notified")
+
+	statusFile = flag.String("status_file", "", "file to periodically write the last activity time to, when socket-activated")
+
+	adminAddr = flag.String("admin_addr", "", "separate address serving /debug/ (pprof, expvar) endpoints; binds to localhost if host is omitted (e.g., ':6061'). If empty, they're served on the main address")
+
+	cgoRedirect = flag.String("cgo_redirect", "/cmd/cgo/", "URL /pkg/C/ (the cgo pseudo-package) redirects to; empty to not redirect it")
+
+	disableFmt = flag.Bool("disable_fmt", false, "don't serve code formatting endpoints (/fmt, /api/fmt/batch)")
+
+	csp     = flag.String("csp", defaultCSP, "Content-Security-Policy header of HTML pages; empty to omit it")
+	preload = flag.Bool("preload", true, "add Link rel=preload headers for stylesheets and scripts of the page chrome to HTML pages")
+
+	robots = flag.String("robots", "", "if not empty, generate robots.txt disallowing these comma-separated paths (e.g. '/src/') and non-documentation endpoints")
+
+	canonicalHost     = flag.String("canonical_host", "", "if not empty, redirect requests for other hosts to this one (host[:port]) and add Link rel=canonical headers to package pages")
+	canonicalRedirect = flag.Bool("canonical_redirect", true, "redirect requests for hosts other than -canonical_host; if false, only Link rel=canonical headers are added to package pages")
+	canonicalQuery    = flag.Bool("canonical_query", false, "redirect /pkg/ and /src/ requests with query parameters godoc doesn't use to the URL without them")
+	basePath          = flag.String("base_path", "", "URL path prefix the server is reachable under on -canonical_host, e.g. behind a reverse proxy")
+
+	slowRequestThreshold = flag.Duration("slow_request_threshold", 0, "log requests taking longer than this; 0 to disable")
+	accessLog            = flag.Bool("access_log", false, "log every request")
+	accessLogFormat      = flag.String("access_log_format", "text", "format of -access_log lines: 'text', 'clf' (NCSA Common Log Format), 'combined' (with referer and user agent) or 'json'")
+	quiet404             = flag.String("quiet_404", "", "comma-separated list of URL path patterns (path.Match syntax, or prefixes ending with '/'), e.g. '/apple-touch-icon*,/.well-known/', whose 404s aren't logged by -access_log and don't reset the inactivity timer")
+	quiet404Sample       = flag.Int("quiet_404_sample", 0, "log every n-th 404 matching -quiet_404 anyway; 0 for none")
+	otelFlag             = flag.Bool("otel", false, "export OpenTelemetry traces of requests over OTLP/HTTP, configured by the standard OTEL_* environment variables (requires building with -tags otel)")
+
+	deepHealth = flag.Bool("deep_health", false, "make /healthz check that the served file system is readable")
+
+	debug      = flag.Bool("debug", false, "enable additional /debug/ endpoints (e.g. /debug/index/rebuild, /debug/popular, /debug/buildcontext)")
+	debugToken = flag.String("debug_token", "", "if not empty, additional /debug/ endpoints require 'Authorization: Bearer <token>' header")
+	viewsFile  = flag.String("views_file", "", "file to keep package view counters (see /debug/popular) in across runs")
 
 	verbose = flag.Bool("v", false, "verbose mode")
 
+	offline = flag.Bool("offline", false, "offline mode: never link to or contact external hosts")
+
 	goroot = flag.String("goroot", runtime.GOROOT(), "Go root directory")
+	goos   = flag.String("goos", build.Default.GOOS, "GOOS whose build constraints select the files documented; ?GOOS= overrides it per request")
+	goarch = flag.String("goarch", build.Default.GOARCH, "GOARCH whose build constraints select the files documented; ?GOARCH= overrides it per request")
 
 	// layout control
 	tabWidth       = flag.Int("tabwidth", 4, "tab width")
+	tabWidthMap    = make(tabWidthMapFlag)
 	showTimestamps = flag.Bool("timestamps", false, "show timestamps with directory listings")
 	declLinks      = flag.Bool("links", true, "link identifiers to their declarations")
 
-	templateDir = flag.String("templates", "", "load templates/JS/CSS from disk in this directory")
+	streamDirlist = flag.Bool("stream_dirlist", false, "stream directory listings under /src/ instead of rendering them at once")
+	showInternal  = flag.Bool("show_internal", false, "list internal packages (and vendored ones) in /pkg/ directory listings without packages of their own, like /pkg/ itself")
+	srcOrigin     = flag.Bool("src_origin", false, "tag /src/ directory listing entries with their origin (goroot, gopath, vendor, zip, modcache) and allow filtering by ?origin=; implies -stream_dirlist")
+	noSrcListing  = flag.Bool("no_src_listing", false, "forbid directory listings under /src/; files are still served")
+	srcExtensions = flag.String("src_extensions", defaultSrcExtensions, "comma-separated list of file extensions served under /src/ ('.' for none); empty to serve all")
+	srcExclude    = flag.String("src_exclude", "", "comma-separated list of file extensions never served under /src/")
+
+	maxDirlistEntries = flag.Int("max_dirlist_entries", 0, "list at most this many entries of a /src/ directory, with a notice that the listing is truncated; 0 for no limit. Implies -stream_dirlist")
+
+	coalesceRenders   = flag.Bool("coalesce_renders", true, "let concurrent identical /pkg/ requests share one render")
+	renderConcurrency = flag.Int("render_concurrency", 0, "maximum number of /pkg/ and /src/ pages rendered at once; 0 for no limit")
+	renderWait        = flag.Duration("render_wait", 5*time.Second, "how long requests over -render_concurrency wait for a render slot before getting 503; 0 to reply 503 at once")
+	initTimeout       = flag.Duration("init_timeout", 0, "exit if reading the file system tree at startup takes longer than this; 0 for no limit")
+	startupJitter     = flag.Duration("startup_jitter", 0, "sleep a random duration up to this before reading the file system tree and indexing")
+	pinStdlib         = flag.Bool("pin_stdlib", false, "render standard library package pages at startup and keep them in memory")
+	pinStdlibMaxBytes = flag.Int64("pin_stdlib_max_bytes", 64<<20, "memory limit for pages kept by -pin_stdlib")
+
+	renderReadme        = flag.Bool("render_readme", false, "show README.md of the package directory on package pages")
+	verifyExamples      = flag.Bool("verify_examples", false, "run examples of packages served from disk with go test in background, and mark them as passing or failing on package pages")
+	highlightDeprecated = flag.Bool("highlight_deprecated", false, "render 'Deprecated:' paragraphs of doc comments as highlighted callouts")
+	examplesExpanded    = flag.Bool("examples_expanded", false, "show examples on package pages expanded instead of collapsed")
+	recentSidebar       = flag.Bool("recent_sidebar", false, "list packages recently viewed by the client on package pages, kept in a cookie")
+	ticketPattern       = flag.String("ticket_pattern", "", "regular expression matching ticket references in doc comments to link, e.g. 'JIRA-[0-9]+'; disabled if empty")
+	ticketURL           = flag.String("ticket_url", "", "URL ticket references matching -ticket_pattern link to; $0 is the reference, $1... are its submatches, e.g. 'https://jira.example.com/browse/$0'")
+
+	siteTitle = flag.String("site_title", "", "site title shown on pages instead of '"+stockSiteTitle+"'")
+	siteLogo  = flag.String("site_logo", "", "URL of a logo image shown next to the site title")
+
+	templateDir     = flag.String("templates", "", "load templates/JS/CSS from disk in this directory")
+	templatesBrotli = flag.Bool("templates_br", false, "serve precompressed file.br from -templates instead of file to clients accepting brotli")
+
+	staticMounts staticMountsFlag
+	xrefMap      xrefMapFlag
+
+	printBindingsFlag = flag.Bool("print_bindings", false, "print the file system bindings (mount point, source, mode) in order of setup and exit")
 
 	// search index
-	indexEnabled  = flag.Bool("index", false, "enable search index")
-	indexFiles    = flag.String("index_files", "", "glob pattern specifying index files; if not empty, the index is read from these files in sorted order")
-	indexInterval = flag.Duration("index_interval", 0, "interval of indexing; 0 for default (5m), negative to only index once at startup")
-	maxResults    = flag.Int("maxresults", 10000, "maximum number of full text search results shown")
-	indexThrottle = flag.Float64("index_throttle", 0.75, "index throttle value; 0.0 = no time allocated, 1.0 = full throttle")
+	indexEnabled      = flag.Bool("index", false, "enable search index")
+	indexFiles        = flag.String("index_files", "", "glob pattern specifying index files; if not empty, the index is read from these files in sorted order")
+	indexInterval     = flag.Duration("index_interval", 0, "interval of indexing; 0 for default (5m), negative to only index once at startup")
+	maxResults        = flag.Int("maxresults", 10000, "maximum number of full text search results shown")
+	indexMaxLoadBytes = flag.Int64("index_max_load_bytes", 0, "if index files are larger than this in total, start with search disabled instead of loading them; 0 for no limit")
+	searchTimeout     = flag.Duration("search_timeout", 10*time.Second, "abandon searches taking longer than this and reply 503; 0 for no limit")
+	maxQueryLen       = flag.Int("max_query_len", 256, "maximum length of search queries; 0 for no limit")
+	indexWindow       = flag.String("index_window", "", "daily period of local time (see TZ) reindexing passes after the first one may start in, e.g. '02:00-04:00'; any time if empty")
+	exportIndexFile   = flag.String("export_index", "", "build the search index, write it to this file as newline-delimited JSON and exit")
+	indexThrottle     = flag.Float64("index_throttle", 0.75, "index throttle value; 0.0 = no time allocated, 1.0 = full throttle")
 
 	// source code notes
 	notesRx = flag.String("notes", "BUG", "regular expression matching note markers to show")
 )
 
+func init() {
+	flag.Var(tabWidthMap, "tabwidth_map", "tab widths of source files by extension, e.g. 'go=4,s=8'; -tabwidth is used for other extensions")
+	flag.Var(&staticMounts, "static_mount", "serve files from disk at URL path, specified as urlpath=diskpath; may be repeated")
+	flag.Var(inactivityTimeout, "inactivity_timeout", "Inactivity timeout for socket activation; may be given per socket name, e.g. 'public=5m,admin=0', activity on sockets with zero timeout doesn't count; or by local time of day, e.g. '09:00-18:00=30m,else=2m'")
+	flag.Var(&xrefMap, "xref_map", "link identifiers of packages under import path prefix to another godoc instance, specified as importprefix=baseurl; may be repeated")
+}
+
 func main() {
+	if err := setFlagsFromEnv(flag.CommandLine); err != nil {
+		fatal(err)
+	}
 	flag.Parse()
 
 	// most of the code is simply copy-pasted from golang.org/x/tools/cmd/godoc/main.go
 
 	var fsGate chan bool
-	fsGate = make(chan bool, 20)
+	fsGate = make(chan bool, fsGateSize)
+
+	// godoc uses build.Default when the request doesn't specify GOOS/GOARCH
+	build.Default.GOOS = *goos
+	build.Default.GOARCH = *goarch
+
+	if *fsysFlag != "os" && *fsysFlag != "iofs" {
+		fatalf("-fsys must be 'os' or 'iofs', not %q", *fsysFlag)
+	}
 
 	// Determine file system to use.
-	if *zipfile == "" {
+	if *embedded {
+		efs := embeddedFS()
+		if efs == nil {
+			fatal("-embedded: the binary was built without the embedded tag")
+		}
+		bind("/", efs, "/", vfs.BindReplace, originGoroot)
+	} else if *zipfile == "" {
 		// use file system of underlying OS
-		rootfs := gatefs.New(vfs.OS(*goroot), fsGate)
-		fs.Bind("/", rootfs, "/", vfs.BindReplace)
+		rootfs := osFS(*goroot, fsGate)
+		bind("/", rootfs, "/", vfs.BindReplace, originGoroot)
 	} else {
 		// use file system specified via .zip file (path separator must be '/')
-		rc, err := zip.OpenReader(*zipfile)
+		rc, err := openZip(*zipfile)
 		if err != nil {
-			log.Fatalf("%s: %s\n", *zipfile, err)
+			fatalf("%s: %s\n", *zipfile, err)
 		}
 		defer rc.Close() // be nice (e.g., -writeIndex mode)
-		fs.Bind("/", zipfs.New(rc, *zipfile), *goroot, vfs.BindReplace)
+		var z vfs.FileSystem = zipfs.New(rc, *zipfile)
+		if *zipCache {
+			z = newCachingFS(z)
+		}
+		bindZip(z, *zipfile)
 	}
 	if *templateDir != "" {
-		fs.Bind("/lib/godoc", vfs.OS(*templateDir), "/", vfs.BindBefore)
+		bind("/lib/godoc", vfs.OS(*templateDir), "/", vfs.BindBefore, "")
 	} else {
-		fs.Bind("/lib/godoc", mapfs.New(static.Files), "/", vfs.BindReplace)
+		bind("/lib/godoc", mapfs.New(static.Files), "/", vfs.BindReplace, "")
 	}
 
 	// Bind $GOPATH trees into Go root.
 	for _, p := range filepath.SplitList(build.Default.GOPATH) {
-		fs.Bind("/src", gatefs.New(vfs.OS(p), fsGate), "/src", vfs.BindAfter)
-	}
-
-	var typeAnalysis, pointerAnalysis bool
-	if *analysisFlag != "" {
-		for _, a := range strings.Split(*analysisFlag, ",") {
-			switch a {
-			case "type":
-				typeAnalysis = true
-			case "pointer":
-				pointerAnalysis = true
-			default:
-				log.Fatalf("unknown analysis: %s", a)
-			}
+		bind("/src", osFS(p, fsGate), "/src", vfs.BindAfter, originGopath)
+	}
+
+	if *zipGlob != "" {
+		for _, rc := range bindZipGlob(*zipGlob) {
+			defer rc.Close()
+		}
+	}
+
+	if *modCache {
+		dir := modCacheDir()
+		if dir == "" {
+			fatal("-mod_cache: neither GOMODCACHE nor GOPATH is set")
+		}
+		bind("/src", newModCacheFS(dir), "/", vfs.BindAfter, originModCache)
+	}
+
+	if *vendorRoot != "" {
+		bindVendorRoot(*vendorRoot, fsGate)
+	}
+
+	if *singleDir != "" {
+		dir, err := filepath.Abs(*singleDir)
+		if err != nil {
+			fatal(err)
+		}
+		singleDirImportPath = dirImportPath(dir)
+		bind("/src/"+singleDirImportPath, osFS(dir, fsGate), "/", vfs.BindBefore, originGopath)
+	}
+
+	for _, m := range staticMounts {
+		bind(m.urlPath, vfs.OS(m.diskPath), "/", vfs.BindReplace, "")
+	}
+
+	if *printBindingsFlag {
+		printBindings(os.Stdout)
+		return
+	}
+
+	if *analysisFlag == "list" {
+		printAnalyses(os.Stdout)
+		return
+	}
+	analysisConf, err := parseAnalyses(*analysisFlag)
+	if err != nil {
+		fatal(err)
+	}
+	if analysisConf.enabled() {
+		analysisPending = 1
+	}
+
+	if err := checkAccessLogFormat(*accessLogFormat); err != nil {
+		fatal(err)
+	}
+	if err := parseTicketFlags(); err != nil {
+		fatal(err)
+	}
+	if err := checkIndexFlags(commandLineIndexFlags()); err != nil {
+		fatal(err)
+	}
+	if *indexEnabled && *indexFiles != "" {
+		err := checkIndexLoadSize(*indexFiles, *indexMaxLoadBytes)
+		if _, ok := err.(*indexSizeError); ok {
+			log.Printf("%v; search is disabled", err)
+			*indexEnabled = false
+		} else if err != nil {
+			fatal(err)
 		}
 	}
 
@@ -125,8 +315,27 @@ func main() {
 	corpus.IndexThrottle = *indexThrottle
 	corpus.IndexInterval = *indexInterval
 
-	if err := corpus.Init(); err != nil {
-		log.Fatal(err)
+	if *startupJitter > 0 {
+		// spread file system load of instances started at once;
+		// the global source isn't seeded before Go 1.20, which would
+		// give every instance the same delay
+		rng := rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())<<32))
+		d := time.Duration(rng.Int63n(int64(*startupJitter)))
+		log.Printf("Delaying startup by %v", d)
+		time.Sleep(d)
+	}
+
+	initCorpus(corpus, *initTimeout)
+
+	if *exportIndexFile != "" {
+		// a single pass, or reading -index_files
+		corpus.IndexEnabled = true
+		corpus.IndexInterval = -1
+		corpus.RunIndexer()
+		if err := exportIndex(corpus, *exportIndexFile); err != nil {
+			fatal("Exporting index: ", err)
+		}
+		return
 	}
 
 	// N.B. global variable defined in adjacent file
@@ -135,28 +344,114 @@ func main() {
 	pres.ShowTimestamps = *showTimestamps
 	pres.ShowPlayground = false
 	pres.DeclLinks = *declLinks
+	if *showInternal {
+		pres.AdjustPageInfoMode = showInternalMode
+	}
 	if *notesRx != "" {
 		pres.NotesRx = regexp.MustCompile(*notesRx)
 	}
 
+	if *viewsFile != "" {
+		if err := pkgViews.load(*viewsFile); err != nil {
+			log.Print("Loading view counters: ", err)
+		}
+	}
+	if *debug {
+		http.Handle("/debug/popular", debugAuthHandler(&pkgViews))
+		http.Handle("/debug/buildcontext", debugAuthHandler(http.HandlerFunc(buildContextHandler)))
+	}
+
+	if *otelFlag {
+		stopTracing, err := setupTracing(serverContext)
+		if err != nil {
+			fatal(err)
+		}
+		shutdowns.add("tracing", shutdownClose, 1, func(ctx context.Context, reason string) error {
+			return stopTracing(ctx)
+		})
+	}
+
 	readTemplates(pres, true)
+	readErrorPages()
 	handler := registerHandlers(pres)
+	if *diffZip != "" {
+		handler.Handle("/diff", newAPIDiffHandler(newZipPresentation(*diffZip), pres))
+	}
+
+	var root http.Handler = recoverHandler{h: handler, pres: pres}
+	if htmlHeaders := pageHeaders(); len(htmlHeaders) > 0 {
+		root = &htmlHeaderHandler{h: root, headers: htmlHeaders}
+	}
+	if *canonicalHost != "" && *canonicalRedirect {
+		root = hostEnforcerHandler{h: root, host: *canonicalHost, basePath: *basePath}
+	}
+	if *slowRequestThreshold > 0 {
+		root = &slowRequestHandler{h: root, threshold: *slowRequestThreshold}
+	}
+	if *accessLog {
+		root = &accessLogHandler{h: root, quiet404: parsePathPatterns(*quiet404), sample: int64(*quiet404Sample), format: *accessLogFormat}
+	}
+	if *otelFlag {
+		root = tracingHandler(root)
+	}
+
+	// Start type/pointer analysis.
+	if analysisConf.enabled() {
+		runAnalysis := func() {
+			runTypeAnalysis(analysisConf, corpus)
+			atomic.StoreInt32(&analysisPending, 0)
+		}
+		shutdowns.add("analysis", shutdownStopJobs, 0, func(ctx context.Context, reason string) error {
+			// godoc/analysis can't be stopped, it just dies with the process
+			if atomic.LoadInt32(&analysisPending) != 0 {
+				log.Print("Abandoning analysis in progress")
+			}
+			return nil
+		})
+		if *analysisLazy {
+			root = lazyAnalysis(root, runAnalysis)
+		} else {
+			go runAnalysis()
+		}
+	}
 
-	http.Handle("/", handler)
+	server := &http.Server{}
+	if *adminAddr != "" {
+		// debug handlers stay on http.DefaultServeMux, which is served by the admin server
+		server.Handler = root
+		startAdminServer(*adminAddr)
+	} else {
+		http.Handle("/", root)
+	}
 
 	// Initialize search index.
 	if *indexEnabled {
-		go corpus.RunIndexer()
+		idx := newIndexer(corpus, *indexInterval)
+		if *indexWindow != "" {
+			w, err := parseTimeWindow(*indexWindow)
+			if err != nil {
+				fatal("-index_window: ", err)
+			}
+			idx.window = &w
+		}
+		if *debug {
+			http.Handle("/debug/index/rebuild", debugAuthHandler(idx))
+		}
+		go idx.run()
+		shutdowns.add("indexer", shutdownStopJobs, 1, func(ctx context.Context, reason string) error {
+			idx.stop()
+			return nil
+		})
 	}
 
-	// Start type/pointer analysis.
-	if typeAnalysis || pointerAnalysis {
-		go analysis.Run(pointerAnalysis, &corpus.Analysis)
+	server.TLSConfig, err = serverTLSConfig()
+	if err != nil {
+		fatal(err)
 	}
 
-	listeners, err := activation.Listeners(true)
+	listeners, err := activationListeners()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	if *verbose {
@@ -166,49 +461,155 @@ func main() {
 		log.Printf("tabwidth = %d", *tabWidth)
 	}
 
-	var ln net.Listener
-
-	server := &http.Server{}
-
-	switch len(listeners) {
-	case 0:
-		var err error
-		ln, err = net.Listen("tcp", *httpAddr)
-		if err != nil {
-			log.Fatal("Failed to listen ", err)
+	shutdownReasons := make(chan string, 2)
+	shutdownDone := make(chan struct{})
+	shutdownOnSignal(shutdownReasons)
+	addServerShutdown(shutdowns, server)
+	if *onIdleExec != "" {
+		shutdowns.add("on_idle_exec", shutdownHooks, 3, func(ctx context.Context, reason string) error {
+			if reason == exitInactivity {
+				runIdleHook(ctx, *onIdleExec)
+			}
+			return nil
+		})
+	}
+	if *viewsFile != "" {
+		shutdowns.add("view counters", shutdownPersist, 1, func(ctx context.Context, reason string) error {
+			return pkgViews.save(*viewsFile)
+		})
+	}
+	go shutdowns.run(shutdownReasons, shutdownDone)
+
+	var lns []net.Listener
+
+	switch {
+	case len(listeners) == 0 || listeners[0].selfBound:
+		// bound by the process itself, now or in an earlier run (see
+		// storeListener); no socket unit would start it again after an
+		// inactivity shutdown
+		var ln net.Listener
+		if len(listeners) == 0 {
+			ln, err = listenTCP(*httpAddr)
+			if err != nil {
+				fatal("Failed to listen ", err)
+			}
+			if *verbose {
+				log.Printf("address = %s", *httpAddr)
+			}
+		} else {
+			ln = listeners[0].Listener
+			if *verbose {
+				log.Printf("address (from fd store) = %s", ln.Addr())
+			}
+		}
+		if *fdStore {
+			// systemd ignores a descriptor it already has
+			if err := storeListener(ln); err != nil {
+				log.Print("Not storing listener in systemd fd store: ", err)
+			}
 		}
-		if *verbose {
-			log.Printf("address = %s", *httpAddr)
+		if len(listeners) > 0 && *tcpKeepAlive != 0 {
+			ln = keepAliveListener{ln, *tcpKeepAlive}
+		}
+		lns = append(lns, ln)
+	default:
+		// the timer starts with the longest current timeout, and the warning
+		// must come before the shortest one can run out
+		var startTimeout, maxTimeout, minTimeout time.Duration
+		for _, l := range listeners {
+			if *tcpKeepAlive != 0 {
+				l.Listener = keepAliveListener{l.Listener, *tcpKeepAlive}
+			}
+			timeouts, own := inactivityTimeout.forName(l.name)
+			d := timeouts[0]
+			if !own {
+				d = inactivityTimeout.at(time.Now())
+			}
+			if d > startTimeout {
+				startTimeout = d
+			}
+			if *verbose {
+				log.Printf("address (socket-activated) = %s (%q, inactivity timeout %v)", l.Addr(), l.name, d)
+			}
+			if own {
+				lns = append(lns, timeoutListener{l.Listener, d})
+			} else {
+				lns = append(lns, l.Listener)
+			}
+			for _, d := range timeouts {
+				// scheduled timeouts change during the day
+				if d > maxTimeout {
+					maxTimeout = d
+				}
+				if d > 0 && (minTimeout == 0 || d < minTimeout) {
+					minTimeout = d
+				}
+			}
 		}
-	case 1:
-		ln = listeners[0]
-		if *verbose {
-			log.Printf("address (socket-activated) = %s", ln.Addr())
+		if maxTimeout == 0 {
+			log.Print("No socket has an inactivity timeout, not shutting down on inactivity")
+			break
 		}
 
-		h := newLastActivityHTTPHandler(server.Handler, *inactivityTimeout)
+		if startTimeout == 0 {
+			// e.g. started outside of the only scheduled window
+			startTimeout = maxTimeout
+		}
+		h := newLastActivityHTTPHandler(server.Handler, startTimeout)
+		h.duration = inactivityTimeout.d
+		if len(inactivityTimeout.schedule) > 0 {
+			h.schedule = inactivityTimeout.at
+		}
+		h.ignoreMethods = parseMethodSet(*inactivityIgnoreMethods)
+		h.quiet404 = parsePathPatterns(*quiet404)
+		h.ignorePaths = map[string]bool{"/healthz": true, "/version": true, "/stats": true, "/index.json": true, "/debug/buildcontext": true}
+		if *idleWarnWebhook != "" {
+			if *idleWarnLead <= 0 || *idleWarnLead >= minTimeout {
+				fatal("-idle_warn_lead must be positive and less than -inactivity_timeout")
+			}
+			addr := lns[0].Addr().String()
+			h.warnBefore(*idleWarnLead, func() {
+				postIdleWarning(*idleWarnWebhook, addr, *idleWarnLead)
+			})
+		}
+		if *statusFile != "" {
+			go writeStatusFile(h, *statusFile)
+		}
+		go logIdleOnSignal(h)
+		if *inactivityTrackConns {
+			h.trackConns()
+			server.ConnState = h.connState
+		}
+		if len(inactivityTimeout.byName) > 0 {
+			server.ConnContext = connTimeoutContext
+		}
+		publishActivityVars(h)
 		server.Handler = h
 		go func() {
-			var err error
 			<-h.timer.C
 			log.Print("HTTP inactivity timeout, shutting down")
-
-			ctx, _ := context.WithTimeout(context.TODO(), time.Second*30)
-			err = server.Shutdown(ctx)
-			if err != nil {
-				log.Print("Error during server shutdown: ", err)
-			}
-			err = server.Close()
-			if err != nil {
-				log.Print("Error during server close: ", err)
-			}
+			shutdownReasons <- exitInactivity
 		}()
-	default:
-		log.Fatal("Unexpected number of sockets passed from systemd: ", len(listeners))
 	}
 
-	err = server.Serve(ln)
-	if err != http.ErrServerClosed {
-		log.Fatal(err)
+	// Serve sets up server.TLSConfig for HTTP/2, so check before it
+	useTLS := server.TLSConfig != nil
+	serveErrs := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func(ln net.Listener) {
+			if useTLS {
+				// certificates are already in server.TLSConfig
+				serveErrs <- server.ServeTLS(ln, "", "")
+			} else {
+				serveErrs <- server.Serve(ln)
+			}
+		}(ln)
+	}
+	for range lns {
+		if err := <-serveErrs; err != http.ErrServerClosed {
+			logExit(exitError, err)
+			os.Exit(1)
+		}
 	}
+	<-shutdownDone
 }
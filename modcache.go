@@ -0,0 +1,131 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/build"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// modVersionRx matches the module versions served, e.g. v1.2.3 or
+// v0.0.0-20170915032832-14c0d48ead0c.
+var modVersionRx = regexp.MustCompile(`^v[0-9A-Za-z.+-]+$`)
+
+// modCacheFS serves versioned module directories of the module cache
+// (GOMODCACHE), read-only: <module>@<version>/... is read from the
+// version's directory, found with the cache's escaping of upper-case
+// letters. Bound after the other trees under /src, it makes
+// /pkg/<module>@<version>/... and /src/<module>@<version>/... pages go
+// through the same handlers, and filters, as any other package.
+// Paths without a version don't exist in it, so it adds nothing to
+// the directory tree of the corpus.
+type modCacheFS struct {
+	vfs.FileSystem // OS file system rooted at the module cache
+	dir            string
+}
+
+func newModCacheFS(dir string) vfs.FileSystem {
+	return modCacheFS{vfs.OS(dir), dir}
+}
+
+// modCacheDir returns the module cache directory, like go env GOMODCACHE.
+func modCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	list := filepath.SplitList(build.Default.GOPATH)
+	if len(list) == 0 || list[0] == "" {
+		return ""
+	}
+	return filepath.Join(list[0], "pkg", "mod")
+}
+
+// cachePath returns the path in the module cache of p,
+// which must start with <module>@<version>.
+func (m modCacheFS) cachePath(op, p string) (string, error) {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	i := strings.Index(p, "@")
+	if i < 0 {
+		return "", &os.PathError{Op: op, Path: p, Err: os.ErrNotExist}
+	}
+	modPath, version, rest := p[:i], p[i+1:], ""
+	if j := strings.Index(version, "/"); j >= 0 {
+		version, rest = version[:j], version[j:]
+	}
+	if !validModPath(modPath) || !modVersionRx.MatchString(version) {
+		return "", &os.PathError{Op: op, Path: p, Err: os.ErrNotExist}
+	}
+	return "/" + escapeModPath(modPath) + "@" + escapeModPath(version) + rest, nil
+}
+
+func (m modCacheFS) Open(p string) (vfs.ReadSeekCloser, error) {
+	cp, err := m.cachePath("open", p)
+	if err != nil {
+		return nil, err
+	}
+	return m.FileSystem.Open(cp)
+}
+
+func (m modCacheFS) Lstat(p string) (os.FileInfo, error) {
+	cp, err := m.cachePath("lstat", p)
+	if err != nil {
+		return nil, err
+	}
+	return m.FileSystem.Lstat(cp)
+}
+
+func (m modCacheFS) Stat(p string) (os.FileInfo, error) {
+	cp, err := m.cachePath("stat", p)
+	if err != nil {
+		return nil, err
+	}
+	return m.FileSystem.Stat(cp)
+}
+
+func (m modCacheFS) ReadDir(p string) ([]os.FileInfo, error) {
+	cp, err := m.cachePath("readdir", p)
+	if err != nil {
+		return nil, err
+	}
+	return m.FileSystem.ReadDir(cp)
+}
+
+func (m modCacheFS) String() string {
+	return "modcache(" + m.dir + ")"
+}
+
+// validModPath tells whether p looks like a module path.
+func validModPath(p string) bool {
+	if p == "" || path.Clean("/"+p) != "/"+p || strings.HasPrefix(p, "-") {
+		return false
+	}
+	for _, r := range p {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-._~/", r))) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeModPath escapes a module path or version for the module cache,
+// replacing upper-case letters with '!' and the lower-case letter.
+func escapeModPath(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			buf.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
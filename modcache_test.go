@@ -0,0 +1,108 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEscapeModPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"golang.org/x/tools", "golang.org/x/tools"},
+		{"github.com/BurntSushi/toml", "github.com/!burnt!sushi/toml"},
+		{"v1.0.0-RC1", "v1.0.0-!r!c1"},
+	}
+	for _, tt := range tests {
+		if got := escapeModPath(tt.in); got != tt.want {
+			t.Errorf("escapeModPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidModPath(t *testing.T) {
+	tests := []struct {
+		p  string
+		ok bool
+	}{
+		{"golang.org/x/tools", true},
+		{"github.com/BurntSushi/toml", true},
+		{"example.com/a~b_c-d", true},
+		{"", false},
+		{"-x", false},
+		{"a/../b", false},
+		{"a//b", false},
+		{"a/", false},
+		{"/a", false},
+		{"a b", false},
+		{"héllo.com", false},
+	}
+	for _, tt := range tests {
+		if got := validModPath(tt.p); got != tt.ok {
+			t.Errorf("validModPath(%q) = %v, want %v", tt.p, got, tt.ok)
+		}
+	}
+}
+
+func TestModCachePath(t *testing.T) {
+	m := modCacheFS{}
+	tests := []struct {
+		p, want string // want is empty if p doesn't exist
+	}{
+		{"/golang.org/x/tools@v0.1.0", "/golang.org/x/tools@v0.1.0"},
+		{"/golang.org/x/tools@v0.1.0/godoc/vfs", "/golang.org/x/tools@v0.1.0/godoc/vfs"},
+		{"golang.org/x/tools@v0.1.0/", "/golang.org/x/tools@v0.1.0"},
+		{"/github.com/BurntSushi/toml@v1.2.0-RC1/x.go", "/github.com/!burnt!sushi/toml@v1.2.0-!r!c1/x.go"},
+		{"/example.com/m@v0.0.0-20170915032832-14c0d48ead0c", "/example.com/m@v0.0.0-20170915032832-14c0d48ead0c"},
+		{"/a/b/../c@v1.0.0/./d", "/a/c@v1.0.0/d"},
+		// no version: nothing of the cache but module directories is served
+		{"/", ""},
+		{"/golang.org/x/tools", ""},
+		{"/cache/download", ""},
+		// bad versions and module paths
+		{"/golang.org/x/tools@", ""},
+		{"/golang.org/x/tools@latest", ""},
+		{"/golang.org/x/tools@v1 2", ""},
+		{"/@v1.0.0", ""},
+		{"/-x@v1.0.0", ""},
+		{"/../../etc@v1.0.0", "/etc@v1.0.0"},
+		{"/a@v1.0.0/../../b@v1.0.0", "/b@v1.0.0"},
+	}
+	for _, tt := range tests {
+		got, err := m.cachePath("stat", tt.p)
+		if tt.want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("cachePath(%q) = %q, %v, want a not exist error", tt.p, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cachePath(%q) = %q, %v, want %q", tt.p, got, err, tt.want)
+		}
+	}
+}
+
+func TestModCacheFS(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "github.com/!burnt!sushi/toml@v1.2.0/toml.go", "package toml\n")
+	writeTestFile(t, dir, "cache/download/github.com/!burnt!sushi/toml/@v/list", "v1.2.0\n")
+	m := newModCacheFS(dir)
+
+	if _, err := m.Stat("/github.com/BurntSushi/toml@v1.2.0/toml.go"); err != nil {
+		t.Errorf("Stat of the module file: %v", err)
+	}
+	list, err := m.ReadDir("/github.com/BurntSushi/toml@v1.2.0")
+	if err != nil || len(list) != 1 || list[0].Name() != "toml.go" {
+		t.Errorf("ReadDir of the module = %v, %v, want toml.go", list, err)
+	}
+	for _, p := range []string{"/", "/cache", "/github.com/!burnt!sushi/toml@v1.2.0/toml.go"} {
+		if _, err := m.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("Stat(%q) = %v, want a not exist error", p, err)
+		}
+	}
+}
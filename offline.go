@@ -0,0 +1,19 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+)
+
+// externalLinkRx matches anchors pointing outside of the served instance,
+// e.g. the godoc.org links to subrepositories in package.html.
+var externalLinkRx = regexp.MustCompile(`(?s)<a\s[^>]*href="(?:https?:)?//[^"]*"[^>]*>(.*?)</a>`)
+
+// stripExternalLinks replaces external anchors in template source with their text,
+// so pages rendered in offline mode never point at golang.org and friends.
+func stripExternalLinks(data []byte) []byte {
+	return externalLinkRx.ReplaceAll(data, []byte("$1"))
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// Origins of bound trees, as reported by originOf.
+const (
+	originGoroot   = "goroot"
+	originGopath   = "gopath"
+	originVendor   = "vendor"
+	originZip      = "zip"
+	originModCache = "modcache"
+)
+
+// mounts mirrors the mount table of a vfs.NameSpace, which doesn't
+// expose which of the bound file systems satisfies a lookup.
+type mounts map[string][]binding
+
+// mountTable mirrors fs. It is filled by bind.
+var mountTable = mounts{}
+
+// bind updates the table the same way vfs.NameSpace.Bind does.
+func (t mounts) bind(b binding) {
+	b.mount = path.Clean(b.mount)
+	b.old = path.Clean(b.old)
+	var mtpt []binding
+	switch b.mode {
+	case vfs.BindReplace:
+		mtpt = append(mtpt, b)
+	case vfs.BindAfter:
+		mtpt = append(mtpt, t.resolve(b.mount)...)
+		mtpt = append(mtpt, b)
+	case vfs.BindBefore:
+		mtpt = append(mtpt, b)
+		mtpt = append(mtpt, t.resolve(b.mount)...)
+	}
+	// Extend inherited entries to the new mount point.
+	for i := range mtpt {
+		m := &mtpt[i]
+		if m.mount != b.mount {
+			suffix := b.mount[len(m.mount):]
+			m.mount = b.mount
+			m.old = path.Join(m.old, suffix)
+		}
+	}
+	t[b.mount] = mtpt
+}
+
+// resolve returns the bindings of the closest mount point at or above name.
+func (t mounts) resolve(name string) []binding {
+	name = path.Clean(name)
+	for {
+		if m := t[name]; m != nil {
+			return m
+		}
+		if name == "/" {
+			return nil
+		}
+		name = path.Dir(name)
+	}
+}
+
+// originOf returns the origin of the first bound tree that has name,
+// the same one fs would serve it from. Directories existing only
+// because something is mounted below them have no origin.
+func originOf(name string) string {
+	name = path.Clean(name)
+	for _, b := range mountTable.resolve(name) {
+		rel := strings.TrimPrefix(name, b.mount)
+		if _, err := b.fs.Lstat(path.Join(b.old, rel)); err == nil {
+			return b.origin
+		}
+	}
+	return ""
+}
+
+// osPath returns the OS path fs serves name from, or false
+// if it's not served from an OS file system made by osFS.
+func osPath(name string) (string, bool) {
+	name = path.Clean(name)
+	for _, b := range mountTable.resolve(name) {
+		p := path.Join(b.old, strings.TrimPrefix(name, b.mount))
+		if _, err := b.fs.Lstat(p); err != nil {
+			continue
+		}
+		dir, ok := osDirs[b.fs]
+		if !ok {
+			return "", false
+		}
+		return filepath.Join(dir, filepath.FromSlash(p)), true
+	}
+	return "", false
+}
+
+// originFileInfo is a directory entry tagged with its origin,
+// available to dirlist.html as {{.Origin}}.
+type originFileInfo struct {
+	os.FileInfo
+	origin string
+}
+
+func (fi originFileInfo) Origin() string { return fi.origin }
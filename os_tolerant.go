@@ -0,0 +1,66 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// tolerantFS is an OS file system whose ReadDir skips entries that
+// can't be examined (e.g. symlinks into a dead network mount, dangling
+// ones or loops), instead of failing the whole directory or listing
+// entries that can't be opened.
+type tolerantFS struct {
+	vfs.FileSystem
+	root string
+}
+
+func (t tolerantFS) ReadDir(name string) ([]os.FileInfo, error) {
+	dir := filepath.Join(t.root, filepath.FromSlash(name))
+	list, err := t.FileSystem.ReadDir(name)
+	if err == nil {
+		return skipBroken(dir, list), nil
+	}
+	f, oerr := os.Open(dir)
+	if oerr != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, nerr := f.Readdirnames(-1)
+	if nerr != nil {
+		return nil, err
+	}
+	list = nil
+	for _, n := range names {
+		fi, err := os.Lstat(filepath.Join(dir, n))
+		if err != nil {
+			log.Printf("Skipping %s: %v", filepath.Join(dir, n), err)
+			continue
+		}
+		list = append(list, fi)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return skipBroken(dir, list), nil
+}
+
+// skipBroken removes symlinks that can't be followed from list of dir.
+func skipBroken(dir string, list []os.FileInfo) []os.FileInfo {
+	kept := list[:0]
+	for _, fi := range list {
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(filepath.Join(dir, fi.Name())); err != nil {
+				log.Printf("Skipping %s: %v", filepath.Join(dir, fi.Name()), err)
+				continue
+			}
+		}
+		kept = append(kept, fi)
+	}
+	return kept
+}
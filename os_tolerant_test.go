@@ -0,0 +1,49 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTolerantDirListing(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "src/p/a.go", "package p\n")
+	writeTestFile(t, dir, "src/p/notes.txt", "notes\n")
+	pdir := filepath.Join(dir, "src", "p")
+	if err := os.Symlink("missing.go", filepath.Join(pdir, "dangling.go")); err != nil {
+		t.Fatal(err)
+	}
+	// can't be read: every lookup fails with ELOOP
+	if err := os.Symlink("loop.txt", filepath.Join(pdir, "loop.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("notes.txt", filepath.Join(pdir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	p := newTestPresentation(t, osFS(dir, nil))
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/src/p/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200\n%s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	for _, name := range []string{"a.go", "notes.txt", "link.txt"} {
+		if !strings.Contains(body, `href="`+name+`"`) {
+			t.Errorf("listing doesn't have %s", name)
+		}
+	}
+	for _, name := range []string{"dangling.go", "loop.txt"} {
+		if strings.Contains(body, name) {
+			t.Errorf("listing has %s, which can't be examined", name)
+		}
+	}
+}
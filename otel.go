@@ -0,0 +1,75 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build otel
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const otelName = "godoc"
+
+// tracingEnabled is set by setupTracing.
+var tracingEnabled bool
+
+// setupTracing installs a tracer provider exporting spans over OTLP/HTTP,
+// configured by the standard OTEL_EXPORTER_OTLP_* and OTEL_SERVICE_NAME
+// (or OTEL_RESOURCE_ATTRIBUTES) variables, and the W3C trace context
+// propagator. The returned func flushes and stops the exporter.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(resource.Default()))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	tracingEnabled = true
+	return tp.Shutdown, nil
+}
+
+// tracingHandler makes a span of each request served by h, named by
+// its route (see tracingRoute), continuing the trace context of the
+// request if any. otelhttp records the status and duration.
+func tracingHandler(h http.Handler) http.Handler {
+	return otelhttp.NewHandler(h, otelName, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + tracingRoute(r.URL.Path)
+	}))
+}
+
+// tracingRoute returns the handler pattern of urlPath, e.g. /pkg/ for
+// /pkg/net/http/, keeping span names few.
+func tracingRoute(urlPath string) string {
+	p := strings.TrimPrefix(urlPath, "/")
+	if i := strings.Index(p, "/"); i >= 0 {
+		return "/" + p[:i+1]
+	}
+	return "/" + p
+}
+
+// traced makes a child span named name of the request span around h,
+// for expensive steps like rendering and searching.
+// It returns h itself without -otel.
+func traced(name string, h http.Handler) http.Handler {
+	if !tracingEnabled {
+		return h
+	}
+	tracer := otel.Tracer(otelName)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), name)
+		defer span.End()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,28 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !otel
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// setupTracing fails, as the binary was built without the otel tag.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	return nil, errors.New("-otel: the binary was built without the otel tag")
+}
+
+// tracingHandler returns h, as tracing is never set up.
+func tracingHandler(h http.Handler) http.Handler {
+	return h
+}
+
+// traced returns h, as tracing is never set up.
+func traced(name string, h http.Handler) http.Handler {
+	return h
+}
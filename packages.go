@@ -0,0 +1,32 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"golang.org/x/tools/godoc"
+)
+
+// listPackages returns import paths of all packages known to the corpus of p,
+// in the same order as the /pkg/ directory listing shows them.
+// The corpus must be initialized.
+func listPackages(p *godoc.Presentation) []godoc.DirEntry {
+	return listPackagesMode(p, 0)
+}
+
+// listPackagesMode is like listPackages, but lists the directory tree
+// in mode, e.g. with godoc.NoFiltering to include internal packages.
+func listPackagesMode(p *godoc.Presentation, mode godoc.PageInfoMode) []godoc.DirEntry {
+	info := p.GetPkgPageInfo("/src", "", mode|godoc.FlatDir)
+	if info.Err != nil || info.Dirs == nil {
+		return nil
+	}
+	var pkgs []godoc.DirEntry
+	for _, d := range info.Dirs.List {
+		if d.HasPkg {
+			pkgs = append(pkgs, d)
+		}
+	}
+	return pkgs
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// pageFilter post-processes a rendered page.
+// It may also set response headers on w.
+type pageFilter func(w http.ResponseWriter, r *http.Request, page []byte) []byte
+
+// pageFilterHandler buffers HTML pages rendered by h and runs filters over them.
+type pageFilterHandler struct {
+	h       http.Handler
+	filters []pageFilter
+
+	// don't filter directory pages (with trailing slash), so that they can be streamed
+	skipDirs bool
+}
+
+func (h *pageFilterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.filters) == 0 || h.skipDirs && strings.HasSuffix(r.URL.Path, "/") {
+		h.h.ServeHTTP(w, r)
+		return
+	}
+	buf := newResponseBuffer()
+	h.h.ServeHTTP(buf, r)
+
+	page := buf.body.Bytes()
+	if buf.status() == http.StatusOK && buf.isHTML() {
+		for _, f := range h.filters {
+			page = f(w, r, page)
+		}
+	}
+	buf.writeTo(w, page)
+}
+
+// pkgImportPath returns import path of the package shown at /pkg/ URL path p.
+func pkgImportPath(p string) string {
+	return strings.Trim(path.Clean(strings.TrimPrefix(p, "/pkg/")), "/")
+}
+
+var footerMarker = []byte(`<div id="footer">`)
+
+// injectBeforeFooter inserts html right after the page content, before the footer.
+func injectBeforeFooter(page, html []byte) []byte {
+	return injectBefore(page, footerMarker, html)
+}
+
+// injectBefore inserts html before marker, if page has it.
+func injectBefore(page, marker, html []byte) []byte {
+	i := bytes.Index(page, marker)
+	if i < 0 {
+		return page
+	}
+	out := make([]byte, 0, len(page)+len(html))
+	out = append(out, page[:i]...)
+	out = append(out, html...)
+	return append(out, page[i:]...)
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/godoc"
+)
+
+// pinnedPages serves package pages rendered once and kept in memory.
+// Only plain requests (no query string) are answered from memory,
+// everything else, including pages that weren't pinned, goes to h.
+// Pinned pages don't reflect analysis results that arrive later.
+type pinnedPages struct {
+	h http.Handler
+
+	mu    sync.RWMutex
+	pages map[string]*responseBuffer // by URL path
+}
+
+func newPinnedPages(h http.Handler) *pinnedPages {
+	return &pinnedPages{h: h, pages: make(map[string]*responseBuffer)}
+}
+
+func (p *pinnedPages) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// godoc renders pages differently for golang.google.cn (GoogleCN),
+	// which it tells by a .cn host, so those aren't served pinned pages.
+	// Requests with a query, e.g. ?m=all or ?googlecn=1, are always rendered.
+	if (r.Method == "GET" || r.Method == "HEAD") && r.URL.RawQuery == "" && !strings.HasSuffix(r.Host, ".cn") {
+		p.mu.RLock()
+		page := p.pages[r.URL.Path]
+		p.mu.RUnlock()
+		if page != nil {
+			page.writeTo(w, page.body.Bytes())
+			return
+		}
+	}
+	p.h.ServeHTTP(w, r)
+}
+
+// pin renders pages of the packages, until they take more than maxBytes in total.
+func (p *pinnedPages) pin(pkgs []godoc.DirEntry, maxBytes int64) {
+	var size int64
+	n := 0
+	for _, d := range pkgs {
+		urlPath := "/pkg/" + d.Path + "/"
+		buf, err := p.render(urlPath)
+		if err != nil {
+			log.Print("Pinning ", urlPath, ": ", err)
+			continue
+		}
+		if buf.status() != http.StatusOK {
+			continue
+		}
+		if size+int64(buf.body.Len()) > maxBytes {
+			log.Printf("Pinned pages reached %d bytes, not pinning the rest", maxBytes)
+			break
+		}
+		size += int64(buf.body.Len())
+		p.mu.Lock()
+		p.pages[urlPath] = buf
+		p.mu.Unlock()
+		n++
+	}
+	log.Printf("Pinned %d package pages (%d bytes)", n, size)
+}
+
+// render renders the page at urlPath. Like net/http does for requests,
+// it recovers from panics in the handler.
+func (p *pinnedPages) render(urlPath string) (buf *responseBuffer, err error) {
+	r, err := http.NewRequest("GET", urlPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	buf = newResponseBuffer()
+	p.h.ServeHTTP(buf, r)
+	return buf, nil
+}
+
+// stdlibPackages returns the standard library packages out of pkgs.
+// Like the go tool, it assumes a package is in the standard library
+// if the first element of its import path has no dot.
+func stdlibPackages(pkgs []godoc.DirEntry) []godoc.DirEntry {
+	var std []godoc.DirEntry
+	for _, d := range pkgs {
+		elem := d.Path
+		if i := strings.Index(elem, "/"); i >= 0 {
+			elem = elem[:i]
+		}
+		if !strings.Contains(elem, ".") {
+			std = append(std, d)
+		}
+	}
+	return std
+}
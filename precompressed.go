@@ -0,0 +1,61 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// brotliHandler serves brotli-compressed siblings (file.br) of files
+// under /lib/godoc/ from dir, the -templates directory, to clients
+// accepting br. Everything else goes to h.
+type brotliHandler struct {
+	h   http.Handler
+	dir string
+}
+
+func (b brotliHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsEncoding(r, "br") {
+		b.h.ServeHTTP(w, r)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/lib/godoc/")
+	f, err := os.Open(filepath.Join(b.dir, filepath.FromSlash(name)+".br"))
+	if err != nil {
+		b.h.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		b.h.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Encoding", "br")
+	// the content type is detected from name, without .br
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
+
+// acceptsEncoding reports whether r has coding in its Accept-Encoding header.
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		v = strings.TrimSpace(v)
+		if i := strings.Index(v, ";"); i >= 0 {
+			if strings.TrimSpace(v[i+1:]) == "q=0" {
+				continue
+			}
+			v = strings.TrimSpace(v[:i])
+		}
+		if strings.EqualFold(v, coding) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// pageQueryParams are the query parameters godoc (and this server)
+// use on /pkg/ and /src/ pages.
+var pageQueryParams = map[string]bool{
+	"m":        true, // page mode
+	"GOOS":     true,
+	"GOARCH":   true,
+	"s":        true, // source selection
+	"h":        true, // source highlight
+	"googlecn": true,
+	"origin":   true, // -src_origin filter
+	"fragment": true, // fragmentHandler
+}
+
+// queryAllowHandler redirects GET requests carrying query
+// parameters other than pageQueryParams to the same URL without them,
+// so arbitrary query strings can't multiply cached or indexed pages.
+// The remaining parameters are put in canonical (sorted) order.
+type queryAllowHandler struct {
+	h        http.Handler
+	basePath string
+}
+
+func (h queryAllowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.RawQuery == "" || (r.Method != "GET" && r.Method != "HEAD") {
+		h.h.ServeHTTP(w, r)
+		return
+	}
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		serveErrorPage(w, http.StatusBadRequest, "malformed query string")
+		return
+	}
+	strip := false
+	for k := range query {
+		if !pageQueryParams[k] {
+			delete(query, k)
+			strip = true
+		}
+	}
+	if !strip {
+		h.h.ServeHTTP(w, r)
+		return
+	}
+	u := url.URL{Path: strings.TrimSuffix(h.basePath, "/") + r.URL.Path, RawQuery: query.Encode()}
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
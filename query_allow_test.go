@@ -0,0 +1,44 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryAllowHandler(t *testing.T) {
+	tests := []struct {
+		method, basePath, url string
+		code                  int
+		location              string
+	}{
+		{"GET", "", "/pkg/fmt/", http.StatusOK, ""},
+		{"GET", "", "/pkg/fmt/?m=all", http.StatusOK, ""},
+		{"GET", "", "/pkg/fmt/?m=all&GOOS=linux&GOARCH=arm", http.StatusOK, ""},
+		{"GET", "", "/src/fmt/print.go?s=10:20&h=Println", http.StatusOK, ""},
+		{"GET", "", "/pkg/fmt/?utm_source=x", http.StatusMovedPermanently, "/pkg/fmt/"},
+		{"GET", "", "/pkg/fmt/?utm_source=x&m=all", http.StatusMovedPermanently, "/pkg/fmt/?m=all"},
+		{"GET", "", "/pkg/fmt/?x=1&m=src&GOOS=linux", http.StatusMovedPermanently, "/pkg/fmt/?GOOS=linux&m=src"},
+		{"GET", "", "/pkg/fmt/?M=all", http.StatusMovedPermanently, "/pkg/fmt/"},
+		{"HEAD", "", "/pkg/fmt/?x=1", http.StatusMovedPermanently, "/pkg/fmt/"},
+		{"GET", "/godoc/", "/pkg/fmt/?x=1", http.StatusMovedPermanently, "/godoc/pkg/fmt/"},
+		{"GET", "/godoc", "/pkg/fmt/?x=1&m=all", http.StatusMovedPermanently, "/godoc/pkg/fmt/?m=all"},
+		{"GET", "", "/pkg/fmt/?m=%zz", http.StatusBadRequest, ""},
+		{"POST", "", "/pkg/fmt/?x=1", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		h := queryAllowHandler{h: okHandler, basePath: tt.basePath}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s %s (base %q): status = %d, want %d", tt.method, tt.url, tt.basePath, w.Code, tt.code)
+		}
+		if got := w.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s %s (base %q): Location = %q, want %q", tt.method, tt.url, tt.basePath, got, tt.location)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"path"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// rawSourceHandler serves raw source files (/src/...?m=text) of fsys with
+// http.ServeContent, which, unlike godoc, answers Range and If-Range
+// requests, so that fetches of large files can be resumed. vfs files
+// are seekable, including zip entries, which zipfs decompresses into
+// memory. Everything else goes to h.
+func rawSourceHandler(fsys vfs.FileSystem, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("m") != "text" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		name := path.Clean(r.URL.Path)
+		fi, err := fsys.Stat(name)
+		if err != nil || !fi.Mode().IsRegular() {
+			h.ServeHTTP(w, r)
+			return
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		// like godoc, whatever the extension
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+	})
+}
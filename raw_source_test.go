@@ -0,0 +1,99 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+var testModTime = time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// writeTestFile writes data to dir/name, creating directories as needed,
+// with testModTime as its modification time.
+func writeTestFile(t *testing.T, dir, name, data string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, testModTime, testModTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRawSourceRange(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("0123456789", 10)
+	writeTestFile(t, dir, "src/p/big.txt", content)
+	notRaw := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not raw", http.StatusTeapot)
+	})
+	h := rawSourceHandler(vfs.OS(dir), notRaw)
+
+	tests := []struct {
+		name        string
+		header      map[string]string
+		wantStatus  int
+		wantRange   string
+		wantContent string
+	}{
+		{"full", nil, http.StatusOK, "", content},
+		{"range", map[string]string{"Range": "bytes=10-19"}, http.StatusPartialContent, "bytes 10-19/100", content[10:20]},
+		{"current if-range", map[string]string{
+			"Range":    "bytes=10-19",
+			"If-Range": testModTime.Format(http.TimeFormat),
+		}, http.StatusPartialContent, "bytes 10-19/100", content[10:20]},
+		{"stale if-range", map[string]string{
+			"Range":    "bytes=10-19",
+			"If-Range": testModTime.Add(-time.Hour).Format(http.TimeFormat),
+		}, http.StatusOK, "", content},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/src/p/big.txt?m=text", nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantRange)
+			}
+			if got := w.Body.String(); got != tt.wantContent {
+				t.Errorf("body = %q, want %q", got, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestRawSourceNotRaw(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "src/p/a.go", "package p\n")
+	notRaw := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := rawSourceHandler(vfs.OS(dir), notRaw)
+	for _, u := range []string{"/src/p/a.go", "/src/p/?m=text", "/src/p/missing.go?m=text"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", u, nil))
+		if w.Code != http.StatusTeapot {
+			t.Errorf("%s: status = %d, want it passed on", u, w.Code)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+	"golang.org/x/tools/godoc/vfs"
+)
+
+var readmePolicy = bluemonday.UGCPolicy()
+
+// readmeFilter appends rendered README.md of the package directory, if any, to the package page.
+func readmeFilter(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+	importPath := pkgImportPath(r.URL.Path)
+	data, err := vfs.ReadFile(fs, path.Join("/src", importPath, "README.md"))
+	if err != nil {
+		return page
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<div id="pkg-readme"><h2>README.md</h2>`)
+	buf.Write(readmePolicy.SanitizeBytes(blackfriday.Run(data)))
+	buf.WriteString("</div>\n")
+	return injectBeforeFooter(page, buf.Bytes())
+}
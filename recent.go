@@ -0,0 +1,128 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	recentCookie      = "godoc_recent"
+	recentMaxPackages = 10
+	recentSep         = "|"
+)
+
+// recentPathRx matches import paths accepted from the cookie.
+var recentPathRx = regexp.MustCompile(`^[A-Za-z0-9._~-]+(/[A-Za-z0-9._~-]+)*$`)
+
+// recentMarker is what {{recent_packages}} expands to in templates.
+// Pages are rendered once for all clients (and may be shared or cached),
+// so recentFilter replaces it with the list of the client afterwards.
+const recentMarker = "<!--godoc:recent_packages-->"
+
+// recentFuncs has the recent_packages template func, placing the list of
+// -recent_sidebar in godoc.html. It's empty without -recent_sidebar, so
+// templates can use it either way. The list is a div with id pkg-recent;
+// pages other than package pages get none.
+var recentFuncs = template.FuncMap{
+	"recent_packages": func() string {
+		if !*recentSidebar {
+			return ""
+		}
+		return recentMarker
+	},
+}
+
+// recentFilter lists the packages recently viewed by the client where
+// the template has {{recent_packages}}, or else before the footer of
+// package pages, and moves the package shown to the front
+// of the list. The list is only kept in a cookie of the client.
+// It must run outside of shared renders and caches (coalescingHandler,
+// pinnedPages, analysisCache), as the pages it makes are per client.
+func recentFilter(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+	w.Header().Add("Vary", "Cookie")
+	recent := readRecent(r)
+	current := pkgImportPath(r.URL.Path)
+	if current == "." || current == "" || !hasGoFiles(path.Join("/src", current)) {
+		// a directory listing, not a package
+		return injectRecent(page, recent)
+	}
+
+	shown := make([]string, 0, len(recent))
+	for _, p := range recent {
+		if p != current {
+			shown = append(shown, p)
+		}
+	}
+	updated := append([]string{current}, shown...)
+	if len(updated) > recentMaxPackages {
+		updated = updated[:recentMaxPackages]
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     recentCookie,
+		Value:    strings.Join(updated, recentSep),
+		Path:     strings.TrimSuffix(*basePath, "/") + "/pkg/",
+		MaxAge:   int((30 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return injectRecent(page, shown)
+}
+
+// readRecent returns the valid import paths of the cookie of r, at most
+// recentMaxPackages of them, without duplicates.
+func readRecent(r *http.Request) []string {
+	c, err := r.Cookie(recentCookie)
+	if err != nil {
+		return nil
+	}
+	var recent []string
+	seen := make(map[string]bool)
+	for _, p := range strings.Split(c.Value, recentSep) {
+		if len(recent) == recentMaxPackages {
+			break
+		}
+		if seen[p] || !recentPathRx.MatchString(p) || path.Clean("/"+p) != "/"+p {
+			continue
+		}
+		seen[p] = true
+		recent = append(recent, p)
+	}
+	return recent
+}
+
+// injectRecent adds the list of recent import paths to page, if there are any.
+func injectRecent(page []byte, recent []string) []byte {
+	list := recentHTML(recent)
+	if bytes.Contains(page, []byte(recentMarker)) {
+		return bytes.Replace(page, []byte(recentMarker), list, -1)
+	}
+	if len(list) == 0 {
+		return page
+	}
+	return injectBeforeFooter(page, list)
+}
+
+// recentHTML returns the list of recent import paths, or nothing if it's empty.
+func recentHTML(recent []string) []byte {
+	if len(recent) == 0 {
+		return nil
+	}
+	var buf strings.Builder
+	buf.WriteString(`<div id="pkg-recent"><h2>Recently viewed</h2><ul>`)
+	for _, p := range recent {
+		p = html.EscapeString(p)
+		buf.WriteString(`<li><a href="/pkg/` + p + `/">` + p + "</a></li>")
+	}
+	buf.WriteString("</ul></div>\n")
+	return []byte(buf.String())
+}
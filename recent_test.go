@@ -0,0 +1,51 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRecent(t *testing.T) {
+	tests := []struct {
+		cookie string
+		want   []string
+	}{
+		{"", nil},
+		{"fmt|net/http|fmt", []string{"fmt", "net/http"}},
+		{"../x|/abs|a//b|a/./b|<b>|ok", []string{"ok"}},
+		{strings.Repeat("p|", 20), []string{"p"}},
+		{"a|b|c|d|e|f|g|h|i|j|k|l", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/pkg/", nil)
+		r.Header.Set("Cookie", recentCookie+"="+tt.cookie)
+		if got := readRecent(r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readRecent(%q) = %q, want %q", tt.cookie, got, tt.want)
+		}
+	}
+}
+
+func TestInjectRecent(t *testing.T) {
+	list := `<div id="pkg-recent"><h2>Recently viewed</h2><ul><li><a href="/pkg/fmt/">fmt</a></li></ul></div>` + "\n"
+	tests := []struct {
+		page   string
+		recent []string
+		want   string
+	}{
+		{`<div id="footer">`, nil, `<div id="footer">`},
+		{`<div id="footer">`, []string{"fmt"}, list + `<div id="footer">`},
+		{`<nav>` + recentMarker + `</nav><div id="footer">`, []string{"fmt"}, `<nav>` + list + `</nav><div id="footer">`},
+		{`<nav>` + recentMarker + `</nav><div id="footer">`, nil, `<nav></nav><div id="footer">`},
+	}
+	for _, tt := range tests {
+		if got := string(injectRecent([]byte(tt.page), tt.recent)); got != tt.want {
+			t.Errorf("injectRecent(%q, %q) = %q, want %q", tt.page, tt.recent, got, tt.want)
+		}
+	}
+}
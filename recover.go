@@ -0,0 +1,72 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	rdebug "runtime/debug" // debug is the -debug flag
+
+	"golang.org/x/tools/godoc"
+)
+
+// recoverHandler recovers from panics in h, logging them with the stack
+// and replying with a 500 error page instead of a blank one.
+// The stack is shown on the page only in -debug mode.
+type recoverHandler struct {
+	h    http.Handler
+	pres *godoc.Presentation
+}
+
+func (h recoverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w}
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+		stack := rdebug.Stack()
+		reqID := ""
+		if id := r.Header.Get("X-Request-Id"); id != "" {
+			reqID = " (request " + id + ")"
+		}
+		log.Printf("panic serving %s %s%s: %v\n%s", r.Method, r.URL.RequestURI(), reqID, v, stack)
+		if rec.code != 0 {
+			// too late for an error page, let net/http drop the connection
+			panic(http.ErrAbortHandler)
+		}
+		h.serveError(w, v, stack)
+	}()
+	h.h.ServeHTTP(rec, r)
+}
+
+func (h recoverHandler) serveError(w http.ResponseWriter, v interface{}, stack []byte) {
+	err := errors.New("Internal server error. Please retry later.")
+	if *debug {
+		err = fmt.Errorf("panic: %v\n\n%s", v, stack)
+	}
+	var body bytes.Buffer
+	if h.pres.ErrorHTML != nil {
+		if e := h.pres.ErrorHTML.Execute(&body, err); e != nil {
+			body.Reset()
+		}
+	}
+	if body.Len() == 0 {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	h.pres.ServePage(w, godoc.Page{
+		Title: "Internal Server Error",
+		Body:  body.Bytes(),
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// renderLimiter bounds the number of package and source pages rendered
+// at once, independently of the file system gate, as rendering is CPU-bound.
+// Excess requests wait up to wait for a slot, then get 503.
+type renderLimiter struct {
+	slots  chan bool
+	wait   time.Duration
+	queued int64 // requests waiting for a slot
+}
+
+func newRenderLimiter(n int, wait time.Duration) *renderLimiter {
+	l := &renderLimiter{slots: make(chan bool, n), wait: wait}
+	expvar.Publish("render_queue_depth", expvar.Func(func() interface{} {
+		return atomic.LoadInt64(&l.queued)
+	}))
+	expvar.Publish("render_in_progress", expvar.Func(func() interface{} {
+		return len(l.slots)
+	}))
+	return l
+}
+
+// limit returns h with its requests counted against l.
+func (l *renderLimiter) limit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acquired := l.acquire(r)
+		if acquired {
+			defer func() { <-l.slots }()
+		}
+		switch {
+		case serverContext.Err() != nil:
+			serveErrorPage(w, http.StatusServiceUnavailable, "the server is shutting down, retry later")
+			return
+		case !acquired:
+			w.Header().Set("Retry-After", "1")
+			serveErrorPage(w, http.StatusServiceUnavailable, "the server is busy rendering other pages, retry later")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (l *renderLimiter) acquire(r *http.Request) bool {
+	select {
+	case l.slots <- true:
+		return true
+	default:
+	}
+	if l.wait <= 0 {
+		return false
+	}
+	atomic.AddInt64(&l.queued, 1)
+	defer atomic.AddInt64(&l.queued, -1)
+	t := time.NewTimer(l.wait)
+	defer t.Stop()
+	select {
+	case l.slots <- true:
+		return true
+	case <-t.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
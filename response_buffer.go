@@ -0,0 +1,71 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// responseBuffer is an http.ResponseWriter keeping the whole response in memory,
+// so that it can be inspected or modified before being sent.
+type responseBuffer struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: make(http.Header)}
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(code int) {
+	if b.code == 0 {
+		b.code = code
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// status returns the response status code, defaulting to 200 like net/http does.
+func (b *responseBuffer) status() int {
+	if b.code == 0 {
+		return http.StatusOK
+	}
+	return b.code
+}
+
+// isHTML reports whether the response is an HTML page.
+func (b *responseBuffer) isHTML() bool {
+	ct := b.header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(b.body.Bytes())
+	}
+	return strings.HasPrefix(ct, "text/html")
+}
+
+// writeTo sends the buffered response, with body replacing the buffered one.
+func (b *responseBuffer) writeTo(w http.ResponseWriter, body []byte) {
+	h := w.Header()
+	for k, v := range b.header {
+		// b may be sent to several responses at once
+		h[k] = append([]string(nil), v...)
+	}
+	if h.Get("Content-Type") == "" {
+		// net/http would sniff the original body, so do it here
+		h.Set("Content-Type", http.DetectContentType(b.body.Bytes()))
+	}
+	h.Del("Content-Length")
+	w.WriteHeader(b.status())
+	w.Write(body)
+}
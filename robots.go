@@ -0,0 +1,48 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// robotsTxt generates robots.txt disallowing the paths listed in -robots,
+// as well as the endpoints that aren't documentation and are actually served.
+func robotsTxt() []byte {
+	var disallow []string
+	for _, p := range strings.Split(*robots, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			disallow = append(disallow, p)
+		}
+	}
+	disallow = append(disallow, "/search", "/api/", "/dl/")
+	if !*disableFmt {
+		disallow = append(disallow, "/fmt")
+	}
+	if *gitRepo != "" {
+		disallow = append(disallow, "/ref/")
+	}
+	if *adminAddr == "" {
+		disallow = append(disallow, "/debug/")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "User-agent: *")
+	fmt.Fprintln(&buf, "Allow: /pkg/")
+	for _, p := range disallow {
+		fmt.Fprintln(&buf, "Disallow:", p)
+	}
+	return buf.Bytes()
+}
+
+func robotsHandler(body []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(body)
+	})
+}
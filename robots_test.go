@@ -0,0 +1,45 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRobotsTxt(t *testing.T) {
+	tests := []struct {
+		disableFmt bool
+		disallow   []string
+		allow      []string // not disallowed
+	}{
+		{false, []string{"/search", "/api/", "/dl/", "/fmt"}, []string{"/pkg/"}},
+		{true, []string{"/search", "/api/", "/dl/"}, []string{"/pkg/", "/fmt"}},
+	}
+	old := *disableFmt
+	t.Cleanup(func() { *disableFmt = old })
+	for _, tt := range tests {
+		*disableFmt = tt.disableFmt
+		lines := strings.Split(string(robotsTxt()), "\n")
+		has := func(p string) bool {
+			for _, l := range lines {
+				if l == "Disallow: "+p {
+					return true
+				}
+			}
+			return false
+		}
+		for _, p := range tt.disallow {
+			if !has(p) {
+				t.Errorf("-disable_fmt=%v: %s isn't disallowed", tt.disableFmt, p)
+			}
+		}
+		for _, p := range tt.allow {
+			if has(p) {
+				t.Errorf("-disable_fmt=%v: %s is disallowed", tt.disableFmt, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// maxQueryRepeats limits the number of repetition operators in a search query.
+const maxQueryRepeats = 8
+
+// checkSearchQuery validates query before it hits the index.
+// Full text search treats query as a regular expression, so
+// overly long or broad ones are rejected.
+func checkSearchQuery(query string) error {
+	if *maxQueryLen > 0 && len(query) > *maxQueryLen {
+		return fmt.Errorf("query is longer than %d bytes", *maxQueryLen)
+	}
+	if n := strings.Count(query, "*") + strings.Count(query, "+") + strings.Count(query, "?") + strings.Count(query, "{"); n > maxQueryRepeats {
+		return fmt.Errorf("query has more than %d repetition operators", maxQueryRepeats)
+	}
+	if rx, err := regexp.Compile(query); err == nil && rx.MatchString("") {
+		return fmt.Errorf("query matches everything, try a more specific one")
+	}
+	return nil
+}
+
+// searchQueryGuard rejects invalid search queries with 400.
+func searchQueryGuard(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if query := strings.TrimSpace(r.FormValue("q")); query != "" {
+			if err := checkSearchQuery(query); err != nil {
+				serveErrorPage(w, http.StatusBadRequest, "bad search query: "+err.Error())
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	rdebug "runtime/debug" // debug is the -debug flag
+	"time"
+)
+
+// searchTimeoutHandler abandons searches that take longer than timeout
+// and answers 503 instead. The index lookup API doesn't accept a context,
+// so the search runs in its own goroutine into a buffer; an abandoned
+// search keeps running until it completes, but its result is discarded.
+type searchTimeoutHandler struct {
+	h       http.Handler
+	timeout time.Duration
+}
+
+func (s *searchTimeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	buf := newResponseBuffer()
+	done := make(chan struct{})
+	var p *searchPanic
+	go func() {
+		defer close(done)
+		// a panic here is outside recoverHandler, pass it back to the request
+		defer func() {
+			if v := recover(); v != nil {
+				p = &searchPanic{v, rdebug.Stack()}
+				if ctx.Err() != nil {
+					log.Printf("panic in abandoned search for %q: %v", r.URL.Query().Get("q"), p)
+				}
+			}
+		}()
+		s.h.ServeHTTP(buf, r.WithContext(ctx))
+	}()
+
+	select {
+	case <-done:
+		if p != nil {
+			if p.v == http.ErrAbortHandler {
+				panic(p.v)
+			}
+			panic(p)
+		}
+		buf.writeTo(w, buf.body.Bytes())
+	case <-ctx.Done():
+		if serverContext.Err() != nil {
+			serveErrorPage(w, http.StatusServiceUnavailable, "the server is shutting down, retry later")
+			return
+		}
+		log.Printf("Search for %q abandoned after %v: %v", r.URL.Query().Get("q"), s.timeout, ctx.Err())
+		serveErrorPage(w, http.StatusServiceUnavailable, "search took too long, try a more specific query")
+	}
+}
+
+// searchPanic is a panic of a search goroutine, re-panicked by the request
+// with the stack of the goroutine.
+type searchPanic struct {
+	v     interface{}
+	stack []byte
+}
+
+func (p *searchPanic) String() string {
+	return fmt.Sprintf("%v\n\nsearch goroutine %s", p.v, p.stack)
+}
@@ -0,0 +1,24 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+func TestSearchTimeoutPanic(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("bad query") })
+	h := recoverHandler{&searchTimeoutHandler{h: panicking, timeout: time.Minute}, newTestPresentation(t, mapfs.New(map[string]string{"src/p/p.go": "package p\n"}))}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/search?q=x", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+}
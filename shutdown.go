@@ -0,0 +1,154 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reasons of process exit, as reported in the final log record.
+const (
+	exitInactivity = "inactivity"
+	exitSignal     = "signal"
+	exitError      = "error"
+)
+
+// logExit emits the final log record, in key=value form for log pipelines.
+func logExit(reason string, err error) {
+	if err != nil {
+		log.Printf("event=exit reason=%s error=%q", reason, err.Error())
+	} else {
+		log.Printf("event=exit reason=%s", reason)
+	}
+}
+
+// fatal is log.Fatal emitting the final log record of logExit
+// before exiting.
+func fatal(v ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprint(v...), "\n")
+	log.Print(msg)
+	logExit(exitError, errors.New(msg))
+	os.Exit(1)
+}
+
+// fatalf is log.Fatalf emitting the final log record of logExit
+// before exiting.
+func fatalf(format string, v ...interface{}) {
+	fatal(fmt.Sprintf(format, v...))
+}
+
+// shutdownOnSignal requests shutdown on SIGTERM or SIGINT.
+func shutdownOnSignal(reasons chan<- string) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-c
+		log.Printf("Received %v, shutting down", sig)
+		reasons <- exitSignal
+	}()
+}
+
+// Phases of shutdown, run in this order. Steps of the same phase
+// run in the order they were added.
+const (
+	shutdownStopServing = iota // stop accepting requests, let those in flight finish
+	shutdownStopJobs           // stop background jobs, like indexing
+	shutdownHooks              // -on_idle_exec
+	shutdownPersist            // save state, like view counters
+	shutdownClose              // close whatever is left
+)
+
+// serverContext is cancelled once shutdown begins. It's the base
+// context of requests (see http.Server.BaseContext), so that handlers
+// doing expensive work can give up; background jobs use it too.
+var serverContext, cancelServerContext = context.WithCancel(context.Background())
+
+// shutdownTimeout is the time budget of the whole shutdown.
+const shutdownTimeout = 30 * time.Second
+
+// shutdownStep is a single step of shutdown. It gets weight shares of
+// the time budget left, plus whatever the steps before it didn't use.
+type shutdownStep struct {
+	name   string
+	phase  int
+	weight int
+	run    func(ctx context.Context, reason string) error
+}
+
+// shutdownCoordinator runs shutdown steps in a deterministic order,
+// dividing shutdownTimeout among them.
+type shutdownCoordinator struct {
+	mu    sync.Mutex
+	steps []shutdownStep
+}
+
+var shutdowns = &shutdownCoordinator{}
+
+// add registers a shutdown step.
+func (c *shutdownCoordinator) add(name string, phase, weight int, run func(ctx context.Context, reason string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps = append(c.steps, shutdownStep{name: name, phase: phase, weight: weight, run: run})
+}
+
+// run waits for the first shutdown request and runs the steps.
+// done is closed once they're all finished.
+func (c *shutdownCoordinator) run(reasons <-chan string, done chan<- struct{}) {
+	reason := <-reasons
+
+	c.mu.Lock()
+	steps := append([]shutdownStep(nil), c.steps...)
+	c.mu.Unlock()
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].phase < steps[j].phase })
+
+	weights := 0
+	for _, step := range steps {
+		weights += step.weight
+	}
+	deadline := time.Now().Add(shutdownTimeout)
+	for _, step := range steps {
+		budget := time.Until(deadline)
+		if weights > 0 {
+			budget = budget * time.Duration(step.weight) / time.Duration(weights)
+		}
+		weights -= step.weight
+		ctx, cancel := context.WithTimeout(context.Background(), budget)
+		if err := step.run(ctx, reason); err != nil {
+			log.Printf("Shutdown: %s: %v", step.name, err)
+		}
+		cancel()
+	}
+	logExit(reason, nil)
+	close(done)
+}
+
+// addServerShutdown registers the steps stopping server: cancelling
+// requests in flight (see serverContext), graceful shutdown letting them
+// finish, then closing connections still open.
+func addServerShutdown(c *shutdownCoordinator, server *http.Server) {
+	server.BaseContext = func(net.Listener) context.Context { return serverContext }
+	c.add("request cancellation", shutdownStopServing, 0, func(ctx context.Context, reason string) error {
+		cancelServerContext()
+		return nil
+	})
+	c.add("HTTP server", shutdownStopServing, 5, func(ctx context.Context, reason string) error {
+		return server.Shutdown(ctx)
+	})
+	c.add("HTTP server close", shutdownClose, 0, func(ctx context.Context, reason string) error {
+		return server.Close()
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// singleDirImportPath is the import path the -single_dir directory is served as.
+var singleDirImportPath string
+
+// dirImportPath guesses the import path of the package in dir:
+// the module path from its go.mod, or the directory name otherwise.
+func dirImportPath(dir string) string {
+	if f, err := os.Open(filepath.Join(dir, "go.mod")); err == nil {
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) == 2 && fields[0] == "module" {
+				if p, err := strconv.Unquote(fields[1]); err == nil {
+					return p
+				}
+				return fields[1]
+			}
+		}
+	}
+	return filepath.Base(dir)
+}
+
+// singleDirRootHandler redirects the root URL to the -single_dir package page.
+type singleDirRootHandler struct {
+	h http.Handler
+}
+
+func (h singleDirRootHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		http.Redirect(w, r, "/pkg/"+singleDirImportPath+"/", http.StatusFound)
+		return
+	}
+	h.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/godoc"
+)
+
+// sitemapMaxURLs is the limit of URLs in a single sitemap, per sitemaps.org.
+const sitemapMaxURLs = 50000
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapLoc `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapHandler serves /sitemap.xml listing the package pages.
+// With more than sitemapMaxURLs packages, it's a sitemap index
+// of /sitemap.xml?page=N, each listing a part of them.
+// Packages under paths disallowed by -robots are left out.
+type sitemapHandler struct {
+	pres *godoc.Presentation
+}
+
+func (h sitemapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base := h.baseURL(r)
+	var pages []string
+	for _, d := range listPackages(h.pres) {
+		p := "/pkg/" + d.Path + "/"
+		if !robotsDisallowed(p) {
+			pages = append(pages, p)
+		}
+	}
+
+	doc := sitemapDoc(base, pages, r.FormValue("page"))
+	if doc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	enc.Encode(doc)
+}
+
+// sitemapDoc returns the sitemap of the URL paths pages, on base: page
+// (numbered from 1) of them if page isn't empty, or else all of them, or
+// the index of the pages if there are more than sitemapMaxURLs.
+// It returns nil if there's no such page.
+func sitemapDoc(base string, pages []string, page string) interface{} {
+	switch {
+	case page != "":
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 || (n-1)*sitemapMaxURLs >= len(pages) {
+			return nil
+		}
+		pages = pages[(n-1)*sitemapMaxURLs:]
+		if len(pages) > sitemapMaxURLs {
+			pages = pages[:sitemapMaxURLs]
+		}
+		fallthrough
+	case len(pages) <= sitemapMaxURLs:
+		set := sitemapURLSet{URLs: make([]sitemapLoc, len(pages))}
+		for i, p := range pages {
+			set.URLs[i].Loc = base + p
+		}
+		return set
+	default:
+		var index sitemapIndex
+		for n := 1; (n-1)*sitemapMaxURLs < len(pages); n++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapLoc{fmt.Sprintf("%s/sitemap.xml?page=%d", base, n)})
+		}
+		return index
+	}
+}
+
+// baseURL returns the URL the server is reachable under,
+// on -canonical_host if set, with -base_path.
+func (h sitemapHandler) baseURL(r *http.Request) string {
+	u := url.URL{Scheme: "http", Host: r.Host, Path: strings.TrimSuffix(*basePath, "/")}
+	if *canonicalHost != "" {
+		u.Host = *canonicalHost
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// robotsDisallowed tells whether -robots disallows crawling urlPath.
+func robotsDisallowed(urlPath string) bool {
+	for _, p := range strings.Split(*robots, ",") {
+		if p = strings.TrimSpace(p); p != "" && strings.HasPrefix(urlPath, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+func sitemapTestPages(n int) []string {
+	pages := make([]string, n)
+	for i := range pages {
+		pages[i] = fmt.Sprintf("/pkg/p%d/", i)
+	}
+	return pages
+}
+
+func TestSitemapDoc(t *testing.T) {
+	const base = "http://example.com"
+	tests := []struct {
+		n     int    // number of pages
+		page  string // page requested
+		urls  int    // URLs in the sitemap, or -1 if it's an index
+		index int    // sitemaps in the index
+		first string // first URL of the sitemap
+	}{
+		{0, "", 0, 0, ""},
+		{3, "", 3, 0, base + "/pkg/p0/"},
+		{sitemapMaxURLs, "", sitemapMaxURLs, 0, base + "/pkg/p0/"},
+		{sitemapMaxURLs + 1, "", -1, 2, ""},
+		{2*sitemapMaxURLs + 1, "", -1, 3, ""},
+		{sitemapMaxURLs + 1, "1", sitemapMaxURLs, 0, base + "/pkg/p0/"},
+		{sitemapMaxURLs + 1, "2", 1, 0, base + fmt.Sprintf("/pkg/p%d/", sitemapMaxURLs)},
+		{sitemapMaxURLs, "1", sitemapMaxURLs, 0, base + "/pkg/p0/"},
+	}
+	for _, tt := range tests {
+		doc := sitemapDoc(base, sitemapTestPages(tt.n), tt.page)
+		switch doc := doc.(type) {
+		case sitemapURLSet:
+			if len(doc.URLs) != tt.urls {
+				t.Errorf("%d pages, page %q: %d URLs, want %d", tt.n, tt.page, len(doc.URLs), tt.urls)
+			} else if tt.urls > 0 && doc.URLs[0].Loc != tt.first {
+				t.Errorf("%d pages, page %q: first URL %s, want %s", tt.n, tt.page, doc.URLs[0].Loc, tt.first)
+			}
+		case sitemapIndex:
+			if tt.urls != -1 || len(doc.Sitemaps) != tt.index {
+				t.Errorf("%d pages, page %q: index of %d sitemaps, want %d URLs, %d sitemaps", tt.n, tt.page, len(doc.Sitemaps), tt.urls, tt.index)
+				continue
+			}
+			for i, s := range doc.Sitemaps {
+				if want := fmt.Sprintf("%s/sitemap.xml?page=%d", base, i+1); s.Loc != want {
+					t.Errorf("%d pages: sitemap %d is %s, want %s", tt.n, i, s.Loc, want)
+				}
+			}
+		default:
+			t.Errorf("%d pages, page %q: got %T", tt.n, tt.page, doc)
+		}
+	}
+
+	for _, tt := range []struct {
+		n    int
+		page string
+	}{
+		{3, "0"}, {3, "-1"}, {3, "x"}, {3, "2"}, {0, "1"}, {sitemapMaxURLs + 1, "3"},
+	} {
+		if doc := sitemapDoc(base, sitemapTestPages(tt.n), tt.page); doc != nil {
+			t.Errorf("%d pages, page %q: got %T, want none", tt.n, tt.page, doc)
+		}
+	}
+}
+
+func TestSitemapHandler(t *testing.T) {
+	p := newTestPresentation(t, mapfs.New(map[string]string{
+		"src/a/a.go":          "package a\n",
+		"src/private/b/b.go":  "package b\n",
+		"src/public/c/c.go":   "package c\n",
+		"src/public/c/d/d.go": "package d\n",
+	}))
+	old := *robots
+	t.Cleanup(func() { *robots = old })
+	*robots = "/pkg/private/"
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/sitemap.xml", nil)
+	r.Host = "godoc.example.com"
+	sitemapHandler{p}.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var set sitemapURLSet
+	if err := xml.Unmarshal(w.Body.Bytes(), &set); err != nil {
+		t.Fatal(err)
+	}
+	var locs []string
+	for _, u := range set.URLs {
+		locs = append(locs, u.Loc)
+	}
+	got := strings.Join(locs, " ")
+	want := "http://godoc.example.com/pkg/a/ http://godoc.example.com/pkg/public/c/ http://godoc.example.com/pkg/public/c/d/"
+	if got != want {
+		t.Errorf("sitemap has %s, want %s", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	sitemapHandler{p}.ServeHTTP(w, httptest.NewRequest("GET", "/sitemap.xml?page=2", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("page 2: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// slowRequestHandler logs requests taking longer than threshold,
+// independently of any other logging.
+type slowRequestHandler struct {
+	h         http.Handler
+	threshold time.Duration
+}
+
+func (s *slowRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	s.h.ServeHTTP(rec, r)
+	if d := time.Since(start); d > s.threshold {
+		client := ""
+		if subject := clientSubject(r); subject != "" {
+			client = ", client " + subject
+		}
+		log.Printf("WARNING: slow request: %s %s took %v, status %d%s", r.Method, r.URL.RequestURI(), d, rec.status(), client)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// defaultSrcExtensions are the file extensions served under /src/ by default.
+// "." stands for files without an extension, like LICENSE or Makefile.
+const defaultSrcExtensions = ".,go,s,c,h,cc,cpp,hh,hpp,m,y,proto,asm,sh,bash,bat,rc,pl,py,awk," +
+	"txt,md,html,css,js,json,xml,yaml,yml,toml,mod,sum,tmpl,golden,in,out,conf,cfg"
+
+// srcFilter applies -no_src_listing, -src_extensions and -src_exclude to h,
+// a handler of /src/ pages of fsys, and serves raw source files of fsys
+// with rawSourceHandler.
+func srcFilter(fsys vfs.FileSystem, h http.Handler) http.Handler {
+	if *noSrcListing {
+		h = noDirListing(fsys, h)
+	}
+	return srcExtensionFilter(fsys, rawSourceHandler(fsys, h), parseExtensions(*srcExtensions), parseExtensions(*srcExclude))
+}
+
+// noDirListing forbids requests for directories of fsys, letting through files only.
+func noDirListing(fsys vfs.FileSystem, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fi, err := fsys.Stat(path.Clean(r.URL.Path)); err == nil && fi.IsDir() {
+			serveErrorPage(w, http.StatusForbidden, "directory listings are disabled")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// parseExtensions parses comma-separated list of file extensions,
+// with or without the leading dot.
+func parseExtensions(s string) map[string]bool {
+	exts := make(map[string]bool)
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "." {
+			exts[""] = true
+		} else if ext = strings.TrimPrefix(ext, "."); ext != "" {
+			exts[ext] = true
+		}
+	}
+	return exts
+}
+
+// srcExtensionFilter replies 404 to requests for files whose extension
+// isn't in allow (unless allow is empty) or is in exclude.
+// Directories of fsys are always let through.
+func srcExtensionFilter(fsys vfs.FileSystem, h http.Handler, allow, exclude map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean(r.URL.Path)
+		if !srcExtensionAllowed(name, allow, exclude) {
+			if fi, err := fsys.Stat(name); err != nil || !fi.IsDir() {
+				serveErrorPage(w, http.StatusNotFound, "file not found")
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// srcExtensionAllowed tells whether the extension of the file name is in
+// allow (or allow is empty) and isn't in exclude.
+func srcExtensionAllowed(name string, allow, exclude map[string]bool) bool {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	return (len(allow) == 0 || allow[ext]) && !exclude[ext]
+}
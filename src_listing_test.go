@@ -0,0 +1,103 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+var srcTestFS = mapfs.New(map[string]string{
+	"src/p/p.go":     "package p\n",
+	"src/p/P.GO":     "package p\n",
+	"src/p/LICENSE":  "license\n",
+	"src/p/p.exe":    "binary\n",
+	"src/p/q/q.go":   "package q\n",
+	"src/p/q.d/x.go": "package x\n",
+})
+
+// okHandler replies 200 to any request.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func TestNoDirListing(t *testing.T) {
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/src/p/p.go", http.StatusOK},
+		{"/src/p/LICENSE", http.StatusOK},
+		{"/src/p/missing.go", http.StatusOK}, // left to h
+		{"/src/p/", http.StatusForbidden},
+		{"/src/p", http.StatusForbidden},
+		{"/src/p/q/", http.StatusForbidden},
+		{"/src/p/q/../", http.StatusForbidden},
+	}
+	h := noDirListing(srcTestFS, okHandler)
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: status = %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"go", []string{"go"}},
+		{".go, .S ,,md", []string{"go", "s", "md"}},
+		{".,go", []string{"", "go"}},
+	}
+	for _, tt := range tests {
+		got := parseExtensions(tt.s)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseExtensions(%q) = %v, want %q", tt.s, got, tt.want)
+			continue
+		}
+		for _, ext := range tt.want {
+			if !got[ext] {
+				t.Errorf("parseExtensions(%q) = %v, want %q", tt.s, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestSrcExtensionFilter(t *testing.T) {
+	tests := []struct {
+		allow, exclude string
+		path           string
+		code           int
+	}{
+		{"", "", "/src/p/p.exe", http.StatusOK}, // no allowlist
+		{"go", "", "/src/p/p.go", http.StatusOK},
+		{"go", "", "/src/p/P.GO", http.StatusOK},
+		{"go", "", "/src/p/p.exe", http.StatusNotFound},
+		{"go", "", "/src/p/LICENSE", http.StatusNotFound},
+		{".,go", "", "/src/p/LICENSE", http.StatusOK},
+		{"go", "", "/src/p/", http.StatusOK},    // directories are let through
+		{"go", "", "/src/p/q.d", http.StatusOK}, // even with an extension
+		{"go", "", "/src/p/x.d", http.StatusNotFound},
+		{"", "exe", "/src/p/p.exe", http.StatusNotFound},
+		{"", "exe", "/src/p/p.go", http.StatusOK},
+		{"go,exe", "exe", "/src/p/p.exe", http.StatusNotFound}, // exclude overrides allow
+		{".,go", ".", "/src/p/LICENSE", http.StatusNotFound},
+		{"", "d", "/src/p/q.d/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		h := srcExtensionFilter(srcTestFS, okHandler, parseExtensions(tt.allow), parseExtensions(tt.exclude))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("allow %q, exclude %q: %s: status = %d, want %d", tt.allow, tt.exclude, tt.path, w.Code, tt.code)
+		}
+	}
+}
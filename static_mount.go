@@ -0,0 +1,56 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// reservedPaths can't be shadowed by static mounts.
+var reservedPaths = []string{"/pkg", "/src", "/cmd", "/lib/godoc"}
+
+type staticMount struct {
+	urlPath  string
+	diskPath string
+}
+
+// staticMountsFlag is a repeatable urlpath=diskpath flag.
+type staticMountsFlag []staticMount
+
+func (f *staticMountsFlag) String() string {
+	var s []string
+	for _, m := range *f {
+		s = append(s, m.urlPath+"="+m.diskPath)
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *staticMountsFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i < 0 {
+		return fmt.Errorf("%q is not in urlpath=diskpath form", value)
+	}
+	urlPath, diskPath := path.Clean("/"+value[:i]), value[i+1:]
+	if diskPath == "" {
+		return fmt.Errorf("empty disk path for %s", urlPath)
+	}
+	for _, r := range reservedPaths {
+		if isPathPrefix(urlPath, r) || isPathPrefix(r, urlPath) {
+			return fmt.Errorf("%s would shadow %s", urlPath, r)
+		}
+	}
+	*f = append(*f, staticMount{urlPath: urlPath, diskPath: diskPath})
+	return nil
+}
+
+// isPathPrefix reports whether slash-separated path p equals prefix or is below it.
+func isPathPrefix(prefix, p string) bool {
+	if prefix == "/" {
+		return true
+	}
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
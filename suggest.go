@@ -0,0 +1,118 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/godoc"
+)
+
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 50
+)
+
+type suggestion struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+
+	key        string // lower-cased Name
+	importance int    // import count of Path
+}
+
+// suggestHandler serves /api/suggest?q=prefix, suggesting identifiers
+// and package names of the search index starting with prefix.
+type suggestHandler struct {
+	corpus *godoc.Corpus
+
+	mu        sync.Mutex
+	indexTime time.Time
+	list      []suggestion // sorted by key
+}
+
+// suggestions returns idents of the current index sorted for prefix lookups,
+// rebuilding them if the index changed. It returns nil if there's no index yet.
+func (s *suggestHandler) suggestions() []suggestion {
+	idx, t := s.corpus.CurrentIndex()
+	if idx == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.Equal(s.indexTime) {
+		return s.list
+	}
+	importCount := idx.ImportCount()
+	seen := make(map[suggestion]bool)
+	var list []suggestion
+	for kind, idents := range idx.Idents() {
+		for name, ids := range idents {
+			for _, id := range ids {
+				sg := suggestion{
+					Name:       name,
+					Path:       id.Path,
+					Kind:       kind.Name(),
+					key:        strings.ToLower(name),
+					importance: importCount[id.Path],
+				}
+				if !seen[sg] {
+					seen[sg] = true
+					list = append(list, sg)
+				}
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].key != list[j].key {
+			return list[i].key < list[j].key
+		}
+		return list[i].Path < list[j].Path
+	})
+	s.indexTime = t
+	s.list = list
+	return list
+}
+
+func (s *suggestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.ToLower(strings.TrimSpace(r.FormValue("q")))
+	n, err := strconv.Atoi(r.FormValue("n"))
+	if err != nil || n <= 0 {
+		n = defaultSuggestions
+	} else if n > maxSuggestions {
+		n = maxSuggestions
+	}
+
+	matches := []suggestion{}
+	if prefix != "" {
+		list := s.suggestions()
+		i := sort.Search(len(list), func(i int) bool { return list[i].key >= prefix })
+		j := i
+		for j < len(list) && strings.HasPrefix(list[j].key, prefix) {
+			j++
+		}
+		matches = append(matches, list[i:j]...)
+	}
+	// shorter names first, then identifiers of more popular packages
+	sort.SliceStable(matches, func(i, j int) bool {
+		if len(matches[i].Name) != len(matches[j].Name) {
+			return len(matches[i].Name) < len(matches[j].Name)
+		}
+		return matches[i].importance > matches[j].importance
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(matches)
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/doc"
+	"go/printer"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/godoc"
+)
+
+// symbolDoc is the documentation of one identifier, served by /api/doc.
+type symbolDoc struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // func, method, type, const or var
+	Doc  string `json:"doc"`
+	Decl string `json:"decl"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// symbolDocHandler serves /api/doc?pkg=fmt&sym=Println (or sym=Type.Method),
+// the documentation of a single exported identifier.
+type symbolDocHandler struct {
+	pres *godoc.Presentation
+}
+
+func (h symbolDocHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pkg := strings.Trim(r.FormValue("pkg"), "/")
+	sym := r.FormValue("sym")
+	if pkg == "" || sym == "" || path.Clean(pkg) != pkg || strings.HasPrefix(pkg, "..") {
+		apiError(w, http.StatusBadRequest, "pkg and sym are required")
+		return
+	}
+	info := h.pres.GetPkgPageInfo("/src/"+pkg, pkg, 0)
+	if info.Err != nil || info.PDoc == nil {
+		apiError(w, http.StatusNotFound, "package not found")
+		return
+	}
+	sd := findSymbol(info.PDoc, sym)
+	if sd == nil {
+		apiError(w, http.StatusNotFound, "symbol not found")
+		return
+	}
+	sd.Name = sym
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: h.pres.TabWidth}
+	if err := cfg.Fprint(&buf, info.FSet, sd.decl); err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sd.Decl = buf.String()
+	pos := info.FSet.Position(sd.decl.Pos())
+	sd.File, sd.Line = pos.Filename, pos.Line
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sd.symbolDoc)
+}
+
+type foundSymbol struct {
+	symbolDoc
+	decl ast.Decl
+}
+
+// findSymbol looks sym up in pdoc: a function, type, constant or variable
+// name, or Type.Method. It returns nil if there's no such identifier.
+func findSymbol(pdoc *doc.Package, sym string) *foundSymbol {
+	if i := strings.Index(sym, "."); i >= 0 {
+		typeName, method := sym[:i], sym[i+1:]
+		for _, t := range pdoc.Types {
+			if t.Name != typeName {
+				continue
+			}
+			for _, f := range t.Methods {
+				if f.Name == method {
+					return funcSymbol(f, "method")
+				}
+			}
+		}
+		return nil
+	}
+
+	if s := findFunc(pdoc.Funcs, sym); s != nil {
+		return s
+	}
+	if s := findValue(pdoc.Consts, sym, "const"); s != nil {
+		return s
+	}
+	if s := findValue(pdoc.Vars, sym, "var"); s != nil {
+		return s
+	}
+	for _, t := range pdoc.Types {
+		if t.Name == sym {
+			return &foundSymbol{symbolDoc{Kind: "type", Doc: t.Doc}, t.Decl}
+		}
+		// constructors and typed values are grouped with their type
+		if s := findFunc(t.Funcs, sym); s != nil {
+			return s
+		}
+		if s := findValue(t.Consts, sym, "const"); s != nil {
+			return s
+		}
+		if s := findValue(t.Vars, sym, "var"); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+func findFunc(funcs []*doc.Func, name string) *foundSymbol {
+	for _, f := range funcs {
+		if f.Name == name {
+			return funcSymbol(f, "func")
+		}
+	}
+	return nil
+}
+
+func funcSymbol(f *doc.Func, kind string) *foundSymbol {
+	return &foundSymbol{symbolDoc{Kind: kind, Doc: f.Doc}, f.Decl}
+}
+
+func findValue(values []*doc.Value, name, kind string) *foundSymbol {
+	for _, v := range values {
+		for _, n := range v.Names {
+			if n == name {
+				return &foundSymbol{symbolDoc{Kind: kind, Doc: valueDoc(v, name)}, v.Decl}
+			}
+		}
+	}
+	return nil
+}
+
+// valueDoc returns the doc comment of the spec declaring name,
+// falling back to the one of the whole declaration group.
+func valueDoc(v *doc.Value, name string) string {
+	for _, spec := range v.Decl.Specs {
+		vs := spec.(*ast.ValueSpec)
+		for _, id := range vs.Names {
+			if id.Name == name && vs.Doc != nil {
+				return vs.Doc.Text()
+			}
+		}
+	}
+	return v.Doc
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tabWidthMapFlag maps file extensions (without dot) to tab widths.
+type tabWidthMapFlag map[string]int
+
+func (f tabWidthMapFlag) String() string {
+	var s []string
+	for ext, width := range f {
+		s = append(s, ext+"="+strconv.Itoa(width))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (f tabWidthMapFlag) Set(value string) error {
+	for _, kv := range strings.Split(value, ",") {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return fmt.Errorf("%q is not in ext=width form", kv)
+		}
+		width, err := strconv.Atoi(kv[i+1:])
+		if err != nil || width <= 0 {
+			return fmt.Errorf("invalid tab width %q", kv[i+1:])
+		}
+		f[strings.TrimPrefix(kv[:i], ".")] = width
+	}
+	return nil
+}
+
+// tabWidthFilter sets tab width of the source file shown on the page
+// according to its extension. Browsers render tabs as 8 spaces by default.
+func tabWidthFilter(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+	width, ok := tabWidthMap[strings.TrimPrefix(path.Ext(r.URL.Path), ".")]
+	if !ok {
+		width = *tabWidth
+	}
+	style := fmt.Sprintf(`<pre style="tab-size: %d; -moz-tab-size: %d">`, width, width)
+	return bytes.Replace(page, []byte("<pre>"), []byte(style), 1)
+}
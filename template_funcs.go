@@ -0,0 +1,29 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"golang.org/x/tools/godoc"
+)
+
+// incompatibleFunc exits because the template func name of godoc,
+// which the option flag wraps, has the unexpected value v.
+// godoc changes signatures of its template funcs from version to version.
+func incompatibleFunc(option, name string, v interface{}) {
+	if v == nil {
+		fatalf("-%s: this golang.org/x/tools version has no %s template func; it's incompatible with -%s", option, name, option)
+	}
+	fatalf("-%s: %s template func of this golang.org/x/tools version is %T; it's incompatible with -%s", option, name, v, option)
+}
+
+// commentHTMLFunc returns the comment_html template func of p, for option to wrap.
+func commentHTMLFunc(p *godoc.Presentation, option string) func(string) string {
+	v := p.FuncMap()["comment_html"]
+	commentHTML, ok := v.(func(string) string)
+	if !ok {
+		incompatibleFunc(option, "comment_html", v)
+	}
+	return commentHTML
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// ticketRx matches -ticket_pattern; nil if it's empty.
+var ticketRx *regexp.Regexp
+
+// parseTicketFlags compiles -ticket_pattern, checking that it comes
+// with -ticket_url.
+func parseTicketFlags() error {
+	if *ticketPattern == "" {
+		if *ticketURL != "" {
+			return errors.New("-ticket_url has no effect without -ticket_pattern")
+		}
+		return nil
+	}
+	if *ticketURL == "" {
+		return errors.New("-ticket_pattern requires -ticket_url")
+	}
+	rx, err := regexp.Compile(*ticketPattern)
+	if err != nil {
+		return errors.New("-ticket_pattern: " + err.Error())
+	}
+	ticketRx = rx
+	return nil
+}
+
+// ticketCommentHTML wraps the comment_html template func, turning
+// references matching -ticket_pattern into links to -ticket_url.
+// Text inside tags, links, and code blocks is left alone.
+func ticketCommentHTML(commentHTML func(string) string) func(string) string {
+	return func(comment string) string {
+		return linkifyTickets(commentHTML(comment))
+	}
+}
+
+// skipElements are elements whose content isn't searched for references.
+var skipElements = []string{"a", "pre", "code"}
+
+func linkifyTickets(s string) string {
+	var buf strings.Builder
+	skip := "" // element being skipped
+	for s != "" {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			i = len(s)
+		}
+		if skip == "" {
+			buf.WriteString(linkifyText(s[:i]))
+		} else {
+			buf.WriteString(s[:i])
+		}
+		s = s[i:]
+		if s == "" {
+			break
+		}
+		j := strings.IndexByte(s, '>')
+		if j < 0 {
+			j = len(s) - 1
+		}
+		tag := s[:j+1]
+		buf.WriteString(tag)
+		s = s[j+1:]
+		name := tagName(tag)
+		if skip == "" {
+			for _, e := range skipElements {
+				if name == e {
+					skip = e
+				}
+			}
+		} else if name == "/"+skip {
+			skip = ""
+		}
+	}
+	return buf.String()
+}
+
+// tagName returns the lowercased name of the tag, with a leading '/'
+// for closing tags.
+func tagName(tag string) string {
+	name := strings.TrimPrefix(tag, "<")
+	if i := strings.IndexAny(name, " \t\n>"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
+
+// linkifyText links references in the HTML-escaped text s.
+// -ticket_url may refer to submatches of -ticket_pattern as in
+// regexp.Expand, e.g. 'https://jira.example.com/browse/$0'.
+func linkifyText(s string) string {
+	text := html.UnescapeString(s)
+	matches := ticketRx.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return s
+	}
+	var buf strings.Builder
+	prev := 0
+	for _, m := range matches {
+		if m[0] == m[1] {
+			continue
+		}
+		buf.WriteString(html.EscapeString(text[prev:m[0]]))
+		url := ticketRx.ExpandString(nil, *ticketURL, text, m)
+		buf.WriteString(`<a href="` + html.EscapeString(string(url)) + `">`)
+		buf.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		buf.WriteString("</a>")
+		prev = m[1]
+	}
+	buf.WriteString(html.EscapeString(text[prev:]))
+	return buf.String()
+}
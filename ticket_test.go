@@ -0,0 +1,81 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestLinkifyTickets(t *testing.T) {
+	oldRx, oldURL := ticketRx, *ticketURL
+	t.Cleanup(func() { ticketRx, *ticketURL = oldRx, oldURL })
+	ticketRx = regexp.MustCompile(`\b([A-Z]+)-([0-9]+)\b`)
+	*ticketURL = "https://jira.example.com/browse/$1-$2?x=1&y=2"
+	const link = `<a href="https://jira.example.com/browse/ABC-12?x=1&amp;y=2">ABC-12</a>`
+
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"nothing here", "nothing here"},
+		{"<p>See ABC-12.</p>", "<p>See " + link + ".</p>"},
+		{"<p>ABC-12 and ABC-12</p>", "<p>" + link + " and " + link + "</p>"},
+		{"<p>&lt;ABC-12&gt;</p>", "<p>&lt;" + link + "&gt;</p>"},
+		{"<p>abc-12 ABC-x</p>", "<p>abc-12 ABC-x</p>"},
+		// tags and their attributes are left alone
+		{`<h3 id="ABC-12">x</h3>`, `<h3 id="ABC-12">x</h3>`},
+		// as is the text of links and code
+		{`<a href="/x">ABC-12</a> ABC-12`, `<a href="/x">ABC-12</a> ` + link},
+		{"<pre>ABC-12\n</pre><p>ABC-12</p>", "<pre>ABC-12\n</pre><p>" + link + "</p>"},
+		{"<CODE>ABC-12</CODE>ABC-12", "<CODE>ABC-12</CODE>" + link},
+		{"<pre class=\"x\">ABC-12</pre>", "<pre class=\"x\">ABC-12</pre>"},
+		// unterminated tag
+		{"ABC-12 <p", link + " <p"},
+	}
+	for _, tt := range tests {
+		if got := linkifyTickets(tt.in); got != tt.want {
+			t.Errorf("linkifyTickets(%q) =\n%q, want\n%q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTagName(t *testing.T) {
+	tests := []struct {
+		tag, want string
+	}{
+		{"<a>", "a"},
+		{`<a href="x">`, "a"},
+		{"</PRE>", "/pre"},
+		{"<code\nclass=x>", "code"},
+	}
+	for _, tt := range tests {
+		if got := tagName(tt.tag); got != tt.want {
+			t.Errorf("tagName(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestParseTicketFlags(t *testing.T) {
+	oldRx, oldPattern, oldURL := ticketRx, *ticketPattern, *ticketURL
+	t.Cleanup(func() { ticketRx, *ticketPattern, *ticketURL = oldRx, oldPattern, oldURL })
+	tests := []struct {
+		pattern, url string
+		ok           bool
+	}{
+		{"", "", true},
+		{"", "https://x/$0", false},
+		{`#\d+`, "", false},
+		{`#(\d+`, "https://x/$1", false},
+		{`#(\d+)`, "https://x/$1", true},
+	}
+	for _, tt := range tests {
+		ticketRx = nil
+		*ticketPattern, *ticketURL = tt.pattern, tt.url
+		if err := parseTicketFlags(); (err == nil) != tt.ok {
+			t.Errorf("-ticket_pattern=%q -ticket_url=%q: err = %v, want ok: %v", tt.pattern, tt.url, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeWindow is a daily period of local time, like 02:00-04:00,
+// wrapping around midnight if the end is before the start (22:00-06:00).
+// The end is exclusive; a window whose start equals its end is the whole day.
+type timeWindow struct {
+	start, end time.Duration // since midnight
+}
+
+// parseTimeWindow parses HH:MM-HH:MM.
+func parseTimeWindow(s string) (timeWindow, error) {
+	var w timeWindow
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return w, fmt.Errorf("time window %q isn't HH:MM-HH:MM", s)
+	}
+	var err error
+	if w.start, err = parseClock(s[:i]); err != nil {
+		return w, err
+	}
+	if w.end, err = parseClock(s[i+1:]); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+func (w timeWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return clock(w.start) + "-" + clock(w.end)
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a HH:MM time of day", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// sinceMidnight returns the time of day of t, in t's location.
+func sinceMidnight(t time.Time) time.Duration {
+	y, m, d := t.Date()
+	return t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+}
+
+// contains tells whether t falls into the window.
+func (w timeWindow) contains(t time.Time) bool {
+	c := sinceMidnight(t)
+	if w.start <= w.end {
+		return w.start == w.end || (w.start <= c && c < w.end)
+	}
+	return c >= w.start || c < w.end
+}
+
+// untilOpen returns how long after t the window opens next; 0 if it's open.
+func (w timeWindow) untilOpen(t time.Time) time.Duration {
+	if w.contains(t) {
+		return 0
+	}
+	d := w.start - sinceMidnight(t)
+	if d < 0 {
+		d += 24 * time.Hour
+	}
+	return d
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+)
+
+// bindVendorRoot binds dir, laid out like a vendor directory (import paths
+// relative to it), into /src ahead of GOROOT and GOPATH trees.
+// As with any union bind, its files shadow files of the same name,
+// while directory contents are merged. Like the other OS trees,
+// it's read through gate.
+func bindVendorRoot(dir string, gate chan bool) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		fatal("-vendor_root: ", err)
+	}
+	if !fi.IsDir() {
+		fatalf("-vendor_root: %s is not a directory", dir)
+	}
+	if *verbose {
+		logShadowedPackages(dir)
+	}
+	bind("/src", osFS(dir, gate), "/", vfs.BindBefore, originVendor)
+}
+
+// logShadowedPackages logs packages in dir that already exist in fs.
+func logShadowedPackages(dir string) {
+	logged := ""
+	filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(p))
+		if err != nil || rel == "." || rel == logged {
+			return nil
+		}
+		logged = rel
+		importPath := filepath.ToSlash(rel)
+		if _, err := fs.Stat("/src/" + importPath); err == nil {
+			log.Printf("vendor_root: %s shadows another copy", importPath)
+		}
+		return nil
+	})
+}
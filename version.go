@@ -0,0 +1,23 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	rdebug "runtime/debug" // debug is the -debug flag
+)
+
+// versionHandler serves /version: the Go version and the main module version, one per line.
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, runtime.Version())
+	version := "(unknown)"
+	if info, ok := rdebug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+	}
+	fmt.Fprintln(w, version)
+}
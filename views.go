@@ -0,0 +1,102 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// pkgViews counts package page views.
+var pkgViews viewCounters
+
+// viewCounters counts views by import path.
+// Incrementing an existing counter takes no locks.
+type viewCounters struct {
+	m sync.Map // import path -> *int64
+}
+
+func (v *viewCounters) add(path string, n int64) {
+	c, ok := v.m.Load(path)
+	if !ok {
+		c, _ = v.m.LoadOrStore(path, new(int64))
+	}
+	atomic.AddInt64(c.(*int64), n)
+}
+
+type viewCount struct {
+	Path  string `json:"path"`
+	Views int64  `json:"views"`
+}
+
+// sorted returns all counters, most viewed first.
+func (v *viewCounters) sorted() []viewCount {
+	var list []viewCount
+	v.m.Range(func(k, c interface{}) bool {
+		list = append(list, viewCount{k.(string), atomic.LoadInt64(c.(*int64))})
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Views != list[j].Views {
+			return list[i].Views > list[j].Views
+		}
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
+
+// counting counts successfully served package pages.
+// Pages that weren't found (or are hidden), and directory listings
+// without a package, aren't counted.
+func (v *viewCounters) counting(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r)
+		if r.Method == "GET" && rec.status() == http.StatusOK {
+			if p := pkgImportPath(r.URL.Path); p != "." && p != "" && hasGoFiles(path.Join("/src", p)) {
+				v.add(p, 1)
+			}
+		}
+	})
+}
+
+// ServeHTTP serves /debug/popular.
+func (v *viewCounters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v.sorted())
+}
+
+// load adds counters saved by save. A missing file isn't an error.
+func (v *viewCounters) load(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var list []viewCount
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, c := range list {
+		v.add(c.Path, c.Views)
+	}
+	return nil
+}
+
+// save writes the counters to filename, replacing it atomically.
+func (v *viewCounters) save(filename string) error {
+	data, err := json.Marshal(v.sorted())
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
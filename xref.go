@@ -0,0 +1,92 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/godoc"
+)
+
+type xref struct {
+	prefix  string // import path prefix
+	baseURL string // godoc instance serving it
+}
+
+// xrefMapFlag is a repeatable importprefix=baseurl flag.
+type xrefMapFlag []xref
+
+func (f *xrefMapFlag) String() string {
+	var s []string
+	for _, x := range *f {
+		s = append(s, x.prefix+"="+x.baseURL)
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *xrefMapFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i < 0 {
+		return fmt.Errorf("%q is not in importprefix=baseurl form", value)
+	}
+	prefix, baseURL := strings.Trim(value[:i], "/"), strings.TrimSuffix(value[i+1:], "/")
+	if prefix == "" || baseURL == "" {
+		return fmt.Errorf("%q is not in importprefix=baseurl form", value)
+	}
+	*f = append(*f, xref{prefix: prefix, baseURL: baseURL})
+	// longest prefix first, so that the most specific one wins
+	sort.SliceStable(*f, func(i, j int) bool { return len((*f)[i].prefix) > len((*f)[j].prefix) })
+	return nil
+}
+
+// baseURL returns the godoc instance serving the package importPath, or "" if it's served locally.
+func (f xrefMapFlag) baseURL(importPath string) string {
+	for _, x := range f {
+		if isPathPrefix("/"+x.prefix, "/"+importPath) {
+			return x.baseURL
+		}
+	}
+	return ""
+}
+
+// declLinkRx matches identifier links generated by godoc (see LinkifyText).
+var declLinkRx = regexp.MustCompile(`<a href="/pkg/([^"#]*?)/(#[^"]*)?">`)
+
+// rewrite points identifier links to packages of mapped prefixes at their instances.
+func (f xrefMapFlag) rewrite(html string) string {
+	return declLinkRx.ReplaceAllStringFunc(html, func(link string) string {
+		m := declLinkRx.FindStringSubmatch(link)
+		base := f.baseURL(m[1])
+		if base == "" {
+			return link
+		}
+		return `<a href="` + base + "/pkg/" + m[1] + "/" + m[2] + `">`
+	})
+}
+
+// funcs returns template funcs overriding those of p that produce identifier links.
+func (f xrefMapFlag) funcs(p *godoc.Presentation) template.FuncMap {
+	funcs := p.FuncMap()
+	nodeHTML, ok := funcs["node_html"].(func(*godoc.PageInfo, interface{}, bool) string)
+	if !ok {
+		incompatibleFunc("xref_map", "node_html", funcs["node_html"])
+	}
+	exampleHTML, ok := funcs["example_html"].(func(*godoc.PageInfo, string) string)
+	if !ok {
+		incompatibleFunc("xref_map", "example_html", funcs["example_html"])
+	}
+	return template.FuncMap{
+		"node_html": func(info *godoc.PageInfo, node interface{}, linkify bool) string {
+			return f.rewrite(nodeHTML(info, node, linkify))
+		},
+		"example_html": func(info *godoc.PageInfo, funcName string) string {
+			return f.rewrite(exampleHTML(info, funcName))
+		},
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"log"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/tools/godoc/vfs"
+	"golang.org/x/tools/godoc/vfs/zipfs"
+)
+
+// bindZipGlob binds the package trees of zip files matching pattern
+// after the other trees under /src, in sorted order, so that they
+// don't shadow packages of the Go root or GOPATH. Like bindZip, it
+// finds the trees in <goroot>/src, src/, or the archive root.
+// The returned archives are to be closed by the caller.
+func bindZipGlob(pattern string) []*zip.ReadCloser {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		fatalf("-zip_glob %s: %v", pattern, err)
+	}
+	if len(names) == 0 {
+		log.Printf("-zip_glob %s matches no files", pattern)
+		return nil
+	}
+	var archives []*zip.ReadCloser
+	for _, name := range names {
+		rc, err := openZip(name)
+		if err != nil {
+			fatalf("%s: %s\n", name, err)
+		}
+		archives = append(archives, rc)
+		var z vfs.FileSystem = zipfs.New(rc, name)
+		if *zipCache {
+			z = newCachingFS(z)
+		}
+		root := "/"
+		for _, dir := range []string{path.Join(*goroot, "src"), "/src"} {
+			if _, err := z.Stat(dir); err == nil {
+				root = dir
+				break
+			}
+		}
+		bind("/src", z, root, vfs.BindAfter, originZip)
+		log.Printf("%s: bound %s of archive at /src", name, root)
+	}
+	return archives
+}
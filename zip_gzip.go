@@ -0,0 +1,49 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// openZip is zip.OpenReader that also accepts gzip-compressed zip files
+// (.zip.gz), detected by their content. Those are decompressed into
+// an unlinked temporary file, as zip needs random access.
+func openZip(name string) (*zip.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	if magic, err := br.Peek(len(gzipMagic)); err != nil || !bytes.Equal(magic, gzipMagic) {
+		return zip.OpenReader(name)
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "godoc-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+	defer os.Remove(tmp.Name()) // zip.OpenReader below keeps its own descriptor
+	if _, err := io.Copy(tmp, gz); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return zip.OpenReader(tmp.Name())
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 WGH. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/godoc/vfs"
+	"golang.org/x/tools/godoc/vfs/mapfs"
+)
+
+// bindZip binds the zip file system z into fs, as laid out by zipBindings.
+func bindZip(z vfs.FileSystem, zipname string) {
+	for _, b := range zipBindings(z, zipname) {
+		bind(b.mount, b.fs, b.old, b.mode, b.origin)
+	}
+}
+
+// zipBindings returns the bindings serving the zip file system z
+// as the Go root. Supported layouts are:
+//
+//	-zip_root/src/...   if -zip_root is set
+//	<goroot>/src/...    the one godoc zip files traditionally have
+//	src/...             Go root at the archive root
+//	<import paths>      packages directly at the archive root
+func zipBindings(z vfs.FileSystem, zipname string) []binding {
+	if *zipRoot != "" {
+		root := path.Join("/", *zipRoot)
+		if _, err := z.Stat(root); err != nil {
+			fatalf("%s: -zip_root %s not found; top-level entries: %s", zipname, *zipRoot, zipTopLevel(z))
+		}
+		return []binding{{"/", z, root, vfs.BindReplace, originZip}}
+	}
+	if _, err := z.Stat(path.Join(*goroot, "src")); err == nil {
+		return []binding{{"/", z, *goroot, vfs.BindReplace, originZip}}
+	}
+	if _, err := z.Stat("/src"); err == nil {
+		log.Printf("%s: no %s in archive, using archive root as Go root", zipname, *goroot)
+		return []binding{{"/", z, "/", vfs.BindReplace, originZip}}
+	}
+	list, err := z.ReadDir("/")
+	if err != nil || len(list) == 0 {
+		fatalf("%s: archive is empty", zipname)
+	}
+	hasDirs := false
+	for _, fi := range list {
+		hasDirs = hasDirs || fi.IsDir()
+	}
+	if !hasDirs {
+		fatalf("%s: no packages found (neither %s/src nor src/); top-level entries: %s; set -zip_root", zipname, *goroot, zipTopLevel(z))
+	}
+	log.Printf("%s: no Go root in archive, serving top-level directories as packages", zipname)
+	return []binding{
+		// an empty Go root, for the corpus to have something at /;
+		// mapfs has no empty directories, so it has a src/ entry,
+		// replaced by the archive
+		{"/", mapfs.New(map[string]string{"src/.empty": ""}), "/", vfs.BindReplace, ""},
+		{"/src", z, "/", vfs.BindReplace, originZip},
+	}
+}
+
+// zipTopLevel returns names of the top-level archive entries, for error messages.
+func zipTopLevel(z vfs.FileSystem) string {
+	list, err := z.ReadDir("/")
+	if err != nil {
+		return err.Error()
+	}
+	var names []string
+	for _, fi := range list {
+		names = append(names, fi.Name())
+	}
+	return strings.Join(names, ", ")
+}